@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"wireworld/sim"
+	"wireworld/ui"
+)
+
+// Action defines a named command which can be bound to keys.
+type Action struct {
+	Name    string       // Unique name, like "sim.toggle".
+	Group   string       // Heading under which the action is listed in the help text.
+	Help    string       // Short description for the help text.
+	Editing bool         // Action applies while an annotation is being edited?
+	Run     func(*Scene) // Performs the action.
+}
+
+// actions defines all known actions, in the order in which they are
+// listed in the help text. Actions with the same group and help text
+// which follow each other are listed as a single entry.
+var actions []*Action
+
+// defaultKeymap defines the default key bindings for each action.
+var defaultKeymap = map[string][]string{
+	"sim.toggle":        {"q"},
+	"sim.step":          {"e"},
+	"sim.faster":        {"equal", "shift-equal", "kp-add"},
+	"sim.slower":        {"minus", "kp-subtract"},
+	"sim.clearSignals":  {"r"},
+	"sim.snapshot":      {"f5"},
+	"sim.restore":       {"f6"},
+	"view.heatmap":      {"f7"},
+	"record.toggle":     {"f9"},
+	"tool.empty":        {"1"},
+	"tool.wire":         {"2"},
+	"tool.head":         {"3"},
+	"tool.tail":         {"4"},
+	"tool.cycleMode":    {"f"},
+	"sim.trim":          {"t"},
+	"selection.all":     {"ctrl-a"},
+	"selection.clear":   {"esc"},
+	"selection.delete":  {"del"},
+	"selection.up":      {"up"},
+	"selection.down":    {"down"},
+	"selection.left":    {"left"},
+	"selection.right":   {"right"},
+	"clipboard.cut":     {"ctrl-x"},
+	"clipboard.copy":    {"ctrl-c"},
+	"clipboard.paste":   {"ctrl-v"},
+	"history.undo":      {"ctrl-z"},
+	"history.redo":      {"ctrl-shift-z"},
+	"annotation.edit":   {"enter", "kp-enter"},
+	"annotation.finish": {"enter", "kp-enter", "esc"},
+	"annotation.erase":  {"backspace"},
+	"annotation.color":  {"tab"},
+	"file.save":         {"ctrl-s"},
+	"file.reload":       {"ctrl-o"},
+	"file.importImage":  {"ctrl-i"},
+	"file.exportImage":  {"ctrl-e"},
+	"file.exportSVG":    {"ctrl-shift-e"},
+	"recovery.restore":  {"ctrl-r"},
+	"recovery.discard":  {"ctrl-shift-r"},
+	"view.info":         {"grave"},
+	"view.grid":         {"f1"},
+	"view.clipboard":    {"f2"},
+	"view.minimap":      {"f3"},
+	"view.theme":        {"f4"},
+	"view.tooltips":     {"f8"},
+	"view.diff":         {"ctrl-d"},
+	"view.diffSignals":  {"ctrl-shift-d"},
+	"view.reset":        {"v"},
+	"view.fitCircuit":   {"home"},
+	"view.fitSelection": {"end"},
+}
+
+func init() {
+	add := func(name, group, help string, run func(*Scene)) *Action {
+		a := &Action{Name: name, Group: group, Help: help, Run: run}
+		actions = append(actions, a)
+		return a
+	}
+
+	add("sim.toggle", "Simulation", "Start/stop simulation", func(s *Scene) { sim.ToggleRunning() })
+	add("sim.step", "Simulation", "Single simulation step", func(s *Scene) { sim.Step(true) })
+	add("sim.faster", "Simulation", "Double simulation speed", func(s *Scene) { sim.ScaleInterval(-1) })
+	add("sim.slower", "Simulation", "Halve simulation speed", func(s *Scene) { sim.ScaleInterval(+1) })
+	add("sim.clearSignals", "Simulation", "Reset electrons to wire (selection or all)", func(s *Scene) { s.canvas.ClearSignals() })
+	add("sim.snapshot", "Simulation", "Snapshot current state", func(s *Scene) { sim.Snapshot() })
+	add("sim.restore", "Simulation", "Restore snapshot", func(s *Scene) { sim.Restore() })
+	add("view.heatmap", "Simulation", "Toggle activity heatmap", func(s *Scene) { s.heatmap.ToggleVisible() })
+
+	add("record.toggle", "Simulation", "Start/stop recording (selection or view)", func(s *Scene) {
+		if err := s.toggleRecording(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+
+	add("tool.empty", "Tools", "Draw Empty cell", func(s *Scene) { s.setTool(sim.CellEmpty) })
+	add("tool.wire", "Tools", "Draw Wire cell", func(s *Scene) { s.setTool(sim.CellWire) })
+	add("tool.head", "Tools", "Draw Electron head", func(s *Scene) { s.setTool(sim.CellHead) })
+	add("tool.tail", "Tools", "Draw Electron tail", func(s *Scene) { s.setTool(sim.CellTail) })
+	add("tool.cycleMode", "Tools", "Cycle draw/fill/net-fill mode", func(s *Scene) { s.cycleDrawMode() })
+	add("sim.trim", "Tools", "Trim empty cells", func(s *Scene) { sim.Trim() })
+	add("selection.all", "Tools", "Select all cells", func(s *Scene) { s.canvas.SelectAll() })
+	add("clipboard.cut", "Tools", "Cut selection", func(s *Scene) { s.canvas.ClipboardCut() })
+	add("clipboard.copy", "Tools", "Copy selection", func(s *Scene) { s.canvas.ClipboardCopy() })
+	add("clipboard.paste", "Tools", "Paste selection", func(s *Scene) { s.canvas.ClipboardPaste() })
+	add("selection.delete", "Tools", "Delete selection", func(s *Scene) { s.canvas.SelectionDelete() })
+	add("selection.up", "Tools", "Move selection", func(s *Scene) { s.canvas.SelectionMove(0, -1) })
+	add("selection.down", "Tools", "Move selection", func(s *Scene) { s.canvas.SelectionMove(0, +1) })
+	add("selection.left", "Tools", "Move selection", func(s *Scene) { s.canvas.SelectionMove(-1, 0) })
+	add("selection.right", "Tools", "Move selection", func(s *Scene) { s.canvas.SelectionMove(+1, 0) })
+	add("selection.clear", "Misc", "Cancel selection / Clear clipboard", func(s *Scene) {
+		s.canvas.SelectionClear()
+		s.canvas.ClipboardClear()
+	})
+	add("history.undo", "Tools", "Undo", func(s *Scene) { s.history.Undo() })
+	add("history.redo", "Tools", "Redo", func(s *Scene) { s.history.Redo() })
+
+	add("annotation.edit", "Annotations", "Add/edit annotation at cursor", func(s *Scene) {
+		x, y := s.canvas.HoverTarget()
+		s.annotations.Edit(x, y)
+	})
+	add("annotation.finish", "Annotations", "Finish editing", func(s *Scene) { s.annotations.EndEdit() }).Editing = true
+	add("annotation.erase", "Annotations", "Delete last character while editing", func(s *Scene) { s.annotations.Backspace() }).Editing = true
+	add("annotation.color", "Annotations", "Cycle colour while editing", func(s *Scene) { s.annotations.CycleColor() }).Editing = true
+
+	add("file.save", "Misc", "Save circuit", func(s *Scene) {
+		if err := s.save(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+	add("file.reload", "Misc", "Reload circuit", func(s *Scene) {
+		if err := s.load(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+	add("file.importImage", "Misc", "Import PNG/GIF image into clipboard", func(s *Scene) {
+		if err := s.importImage(s.siblingFile(".png")); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+	add("file.exportImage", "Misc", "Export selection or circuit as PNG image", func(s *Scene) {
+		if err := s.exportImage(s.siblingFile(".png")); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+	add("file.exportSVG", "Misc", "Export selection or circuit as SVG diagram", func(s *Scene) {
+		if err := s.exportSVG(s.siblingFile(".svg")); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+	add("recovery.restore", "Misc", "Restore work from an unclean exit", func(s *Scene) {
+		if err := s.restoreRecovery(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+	add("recovery.discard", "Misc", "Discard work from an unclean exit", func(s *Scene) {
+		if err := s.discardRecovery(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+	add("view.info", "Misc", "Show/hide this info panel", func(s *Scene) { s.infoVisible = !s.infoVisible })
+	add("view.grid", "Misc", "Toggle grid visibility", func(s *Scene) { s.canvas.ToggleGridVisible() })
+	add("view.clipboard", "Misc", "Toggle clipboard visibility", func(s *Scene) { s.canvas.ToggleDrawClipboard() })
+	add("view.minimap", "Misc", "Toggle minimap visibility", func(s *Scene) { s.minimap.ToggleVisible() })
+	add("view.theme", "Misc", "Cycle colour theme", func(s *Scene) { ui.CycleTheme() })
+	add("view.tooltips", "Misc", "Toggle cursor tooltips", func(s *Scene) { s.statusBar.ToggleTooltip() })
+	add("view.diff", "Misc", "Highlight changes since last save (drop a file to compare with it)", func(s *Scene) {
+		if err := s.toggleDiff(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+	add("view.diffSignals", "Misc", "Toggle comparing signals, not just wiring", func(s *Scene) { s.toggleDiffSignals() })
+	add("view.reset", "Misc", "Reset viewport", func(s *Scene) {
+		s.canvas.SetZoom(ui.ZoomDefault)
+		s.canvas.ScrollTo(0, 0)
+	})
+	add("view.fitCircuit", "Misc", "Zoom to fit circuit", func(s *Scene) { s.canvas.FrameCells(sim.Cells().Bounds()) })
+	add("view.fitSelection", "Misc", "Zoom to selection", func(s *Scene) { s.canvas.FrameCells(s.canvas.SelectionBounds()) })
+
+	// Camera bookmarks, one action per slot.
+	for i := 0; i <= 9; i++ {
+		slot := i
+		add(fmt.Sprintf("camera.store.%d", slot), "Misc", "Store camera bookmark",
+			func(s *Scene) { s.storeBookmark(slot) })
+		defaultKeymap[fmt.Sprintf("camera.store.%d", slot)] = []string{fmt.Sprintf("ctrl-%d", slot)}
+	}
+
+	// Plain digits are taken by the drawing tools, so bookmarks are
+	// recalled with alt+digit instead.
+	for i := 0; i <= 9; i++ {
+		slot := i
+		add(fmt.Sprintf("camera.recall.%d", slot), "Misc", "Recall camera bookmark (plain digits select tools)",
+			func(s *Scene) { s.recallBookmark(slot) })
+		defaultKeymap[fmt.Sprintf("camera.recall.%d", slot)] = []string{fmt.Sprintf("alt-%d", slot)}
+	}
+}
+
+// findAction returns the action with the given name, or nil if
+// there is none.
+func findAction(name string) *Action {
+	for _, a := range actions {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// isAction returns true if name refers to a known action.
+func isAction(name string) bool {
+	return findAction(name) != nil
+}
+
+// loadKeymap creates the default keymap, and applies the bindings from
+// the given file on top of it. A missing file is not an error.
+func loadKeymap(file string) (*ui.Keymap, error) {
+	km, err := ui.NewKeymap(defaultKeymap)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(file) > 0 {
+		if err := km.Load(file, isAction); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return km, nil
+}
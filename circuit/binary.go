@@ -0,0 +1,382 @@
+package circuit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"wireworld/sim"
+)
+
+// BinaryExt defines the file extension for the binary format.
+const BinaryExt = ".wwb"
+
+// binaryMagic identifies a file in the binary format, including its version.
+var binaryMagic = []byte("WWB\x01")
+
+// Record kinds in the binary format.
+const (
+	binaryEnd    = 0 // End of the cell data.
+	binaryColumn = 1 // Start of a new column: x delta, start y.
+	binaryRun    = 2 // Run of cells in the column: y gap, length, packed states.
+)
+
+// binaryMaxRun defines the largest number of cells in a single run.
+// Longer runs are split. This bounds the memory needed by readers.
+const binaryMaxRun = 1 << 16
+
+// IsBinary returns true if the given leading bytes of a file
+// identify the binary format.
+func IsBinary(head []byte) bool {
+	return bytes.HasPrefix(head, binaryMagic)
+}
+
+// BinaryWriter writes cells in the compact binary format.
+//
+// The format consists of a magic number, followed by a gzip stream of
+// records. Cells are stored by column. Each column holds runs of
+// vertically adjacent cells, with their states packed into 2 bits each.
+// Empty cells are not stored.
+//
+// Cells have to be written in the order defined by sim.CellList:
+// sorted by X, then Y. They are streamed out, so only a single run
+// is held in memory at any time.
+type BinaryWriter struct {
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	tmp     [binary.MaxVarintLen64]byte
+	started bool
+	x, y    int32   // Last cell written.
+	end     int32   // Y coordinate after the last run in the column.
+	runY    int32   // Y coordinate of the first cell in the current run.
+	run     []uint8 // States in the current run.
+}
+
+// NewBinaryWriter creates a writer which streams cells to w.
+// Close must be called to finish the stream.
+func NewBinaryWriter(w io.Writer) (*BinaryWriter, error) {
+	if _, err := w.Write(binaryMagic); err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	return &BinaryWriter{
+		gz:  gz,
+		buf: bufio.NewWriter(gz),
+	}, nil
+}
+
+// Write adds a single cell. Empty cells are skipped.
+func (bw *BinaryWriter) Write(x, y, state int32) error {
+	if state == sim.CellEmpty {
+		return nil
+	}
+
+	if state < 0 || state > 3 {
+		return fmt.Errorf("invalid state %d at %d,%d", state, x, y)
+	}
+
+	if bw.started && (x < bw.x || (x == bw.x && y <= bw.y)) {
+		return fmt.Errorf("cell %d,%d is out of order", x, y)
+	}
+
+	switch {
+	case !bw.started || x != bw.x:
+		if err := bw.flushRun(); err != nil {
+			return err
+		}
+
+		bw.putUvarint(binaryColumn)
+		bw.putVarint(int64(x) - int64(bw.x))
+		bw.putVarint(int64(y))
+		bw.end = y
+		bw.runY = y
+
+	case y != bw.runY+int32(len(bw.run)) || len(bw.run) == binaryMaxRun:
+		if err := bw.flushRun(); err != nil {
+			return err
+		}
+		bw.runY = y
+	}
+
+	bw.started = true
+	bw.x, bw.y = x, y
+	bw.run = append(bw.run, uint8(state))
+	return nil
+}
+
+// WriteList adds all cells in the given list, which must be sorted.
+func (bw *BinaryWriter) WriteList(cells sim.CellList) error {
+	for i := 0; i < len(cells)-2; i += 3 {
+		if err := bw.Write(cells[i], cells[i+1], cells[i+2]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close finishes the stream. It does not close the underlying writer.
+func (bw *BinaryWriter) Close() error {
+	if err := bw.flushRun(); err != nil {
+		return err
+	}
+
+	bw.putUvarint(binaryEnd)
+
+	if err := bw.buf.Flush(); err != nil {
+		return err
+	}
+
+	return bw.gz.Close()
+}
+
+// flushRun writes the current run, if any.
+func (bw *BinaryWriter) flushRun() error {
+	if len(bw.run) == 0 {
+		return nil
+	}
+
+	bw.putUvarint(binaryRun)
+	bw.putUvarint(uint64(bw.runY - bw.end))
+	bw.putUvarint(uint64(len(bw.run)))
+
+	// Pack four states into each byte.
+	for i := 0; i < len(bw.run); i += 4 {
+		var b uint8
+		for j := 0; j < 4 && i+j < len(bw.run); j++ {
+			b |= bw.run[i+j] << (uint(j) * 2)
+		}
+		bw.buf.WriteByte(b)
+	}
+
+	bw.end = bw.runY + int32(len(bw.run))
+	bw.run = bw.run[:0]
+
+	// Buffered write errors are sticky, so checking here
+	// covers all writes since the last flush.
+	if bw.buf.Available() == 0 {
+		return bw.buf.Flush()
+	}
+	return nil
+}
+
+func (bw *BinaryWriter) putUvarint(v uint64) {
+	n := binary.PutUvarint(bw.tmp[:], v)
+	bw.buf.Write(bw.tmp[:n])
+}
+
+func (bw *BinaryWriter) putVarint(v int64) {
+	n := binary.PutVarint(bw.tmp[:], v)
+	bw.buf.Write(bw.tmp[:n])
+}
+
+// BinaryReader reads cells in the compact binary format, one at a time.
+// Refer to BinaryWriter for a description of the format.
+type BinaryReader struct {
+	gz   *gzip.Reader
+	r    *bufio.Reader
+	x    int32
+	end  int32
+	runY int32
+	run  []uint8
+	next int // Index of the next cell in run.
+	done bool
+}
+
+// NewBinaryReader creates a reader which streams cells from r.
+func NewBinaryReader(r io.Reader) (*BinaryReader, error) {
+	head := make([]byte, len(binaryMagic))
+	if _, err := io.ReadFull(r, head); err != nil || !IsBinary(head) {
+		return nil, errors.New("not a binary circuit file")
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BinaryReader{
+		gz: gz,
+		r:  bufio.NewReader(gz),
+	}, nil
+}
+
+// Next returns the next cell. Cells are returned in the order defined
+// by sim.CellList. Returns io.EOF when there are no more cells.
+func (br *BinaryReader) Next() (x, y, state int32, err error) {
+	for br.next >= len(br.run) {
+		if br.done {
+			return 0, 0, 0, io.EOF
+		}
+
+		if err := br.readRecord(); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+
+	i := br.next
+	br.next++
+	return br.x, br.runY + int32(i), int32(br.run[i]), nil
+}
+
+// ReadList reads all remaining cells into a sorted cell list.
+func (br *BinaryReader) ReadList() (sim.CellList, error) {
+	var out sim.CellList
+
+	for {
+		x, y, state, err := br.Next()
+		if err == io.EOF {
+			return out, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, x, y, state)
+	}
+}
+
+// readRecord reads the next record.
+func (br *BinaryReader) readRecord() error {
+	kind, err := binary.ReadUvarint(br.r)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	switch kind {
+	case binaryEnd:
+		br.done = true
+
+		// Read the stream to its end, so gzip verifies its checksum.
+		if _, err := br.r.ReadByte(); err != io.EOF {
+			if err == nil {
+				return errors.New("unexpected data after the end record")
+			}
+			return err
+		}
+
+		return br.gz.Close()
+
+	case binaryColumn:
+		dx, err := binary.ReadVarint(br.r)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		y, err := binary.ReadVarint(br.r)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		br.x += int32(dx)
+		br.end = int32(y)
+		br.run = br.run[:0]
+		br.next = 0
+
+	case binaryRun:
+		gap, err := binary.ReadUvarint(br.r)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		n, err := binary.ReadUvarint(br.r)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		if n == 0 || n > binaryMaxRun {
+			return fmt.Errorf("invalid run length %d", n)
+		}
+
+		packed := make([]byte, (n+3)/4)
+		if _, err := io.ReadFull(br.r, packed); err != nil {
+			return unexpectedEOF(err)
+		}
+
+		br.run = br.run[:0]
+		for i := 0; i < int(n); i++ {
+			br.run = append(br.run, (packed[i/4]>>(uint(i%4)*2))&3)
+		}
+
+		br.runY = br.end + int32(gap)
+		br.end = br.runY + int32(n)
+		br.next = 0
+
+	default:
+		return fmt.Errorf("invalid record kind %d", kind)
+	}
+
+	return nil
+}
+
+// unexpectedEOF turns io.EOF into io.ErrUnexpectedEOF. A valid stream
+// always ends with an end record.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// WriteBinary writes the given sorted cells to w in the binary format.
+func WriteBinary(w io.Writer, cells sim.CellList) error {
+	bw, err := NewBinaryWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := bw.WriteList(cells); err != nil {
+		return err
+	}
+
+	return bw.Close()
+}
+
+// ReadBinary reads cells in the binary format from r.
+// The returned list is sorted.
+func ReadBinary(r io.Reader) (sim.CellList, error) {
+	br, err := NewBinaryReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return br.ReadList()
+}
+
+// SaveBinaryFile writes the given sorted cells to a file in the binary format.
+func SaveBinaryFile(name string, cells sim.CellList) error {
+	fd, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err = WriteBinary(fd, cells); err != nil {
+		fd.Close()
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	return fd.Close()
+}
+
+// LoadBinaryFile reads cells in the binary format from the given file.
+func LoadBinaryFile(name string) (sim.CellList, error) {
+	fd, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fd.Close()
+
+	cells, err := ReadBinary(bufio.NewReader(fd))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return cells, nil
+}
@@ -0,0 +1,352 @@
+package circuit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"wireworld/sim"
+)
+
+// randomCells returns n random, non-empty cells with unique coordinates
+// inside a square of the given size, as a sorted list.
+func randomCells(seed int64, n int, size int32) sim.CellList {
+	rng := rand.New(rand.NewSource(seed))
+	seen := make(map[[2]int32]bool)
+
+	var out sim.CellList
+	for len(seen) < n {
+		x := rng.Int31n(size) - size/2
+		y := rng.Int31n(size) - size/2
+		if seen[[2]int32{x, y}] {
+			continue
+		}
+
+		seen[[2]int32{x, y}] = true
+		out = append(out, x, y, 1+rng.Int31n(3))
+	}
+
+	out.Sort()
+	return out
+}
+
+// binaryTestCircuits returns cell lists which cover the edge cases of
+// the binary format.
+func binaryTestCircuits() map[string]sim.CellList {
+	const min, max = math.MinInt32, math.MaxInt32
+
+	// A single column, longer than the longest run.
+	long := make(sim.CellList, 0, (binaryMaxRun*2+5)*3)
+	for y := int32(0); y < binaryMaxRun*2+5; y++ {
+		long = append(long, 7, y-binaryMaxRun, 1+y%3)
+	}
+
+	return map[string]sim.CellList{
+		"empty":  nil,
+		"single": {3, -4, sim.CellHead},
+		"random": randomCells(1, 5000, 200),
+		"sparse": randomCells(2, 500, 1<<30),
+		"long":   long,
+		"extreme": {
+			min, min, sim.CellWire,
+			min, min + 1, sim.CellHead,
+			min, max, sim.CellTail,
+			-1, 0, sim.CellWire,
+			0, min, sim.CellWire,
+			0, max - 1, sim.CellHead,
+			0, max, sim.CellWire,
+			max, min, sim.CellTail,
+			max, 0, sim.CellWire,
+			max, max, sim.CellHead,
+		},
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for name, cells := range binaryTestCircuits() {
+		var buf bytes.Buffer
+		if err := WriteBinary(&buf, cells); err != nil {
+			t.Fatalf("%s: write: %v", name, err)
+		}
+
+		got, err := ReadBinary(&buf)
+		if err != nil {
+			t.Fatalf("%s: read: %v", name, err)
+		}
+
+		if !equalCells(got, cells) {
+			t.Fatalf("%s: cells differ after round trip", name)
+		}
+	}
+}
+
+func TestBinaryLongRunsAreSplit(t *testing.T) {
+	cells := binaryTestCircuits()["long"]
+
+	var buf bytes.Buffer
+	if err := WriteBinary(&buf, cells); err != nil {
+		t.Fatal(err)
+	}
+
+	// Count the run records in the decompressed stream.
+	raw := decompressBinary(t, buf.Bytes())
+	r := bytes.NewReader(raw)
+
+	var runs int
+	for {
+		kind, err := binary.ReadUvarint(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if kind == binaryEnd {
+			break
+		}
+
+		if kind == binaryColumn {
+			binary.ReadVarint(r)
+			binary.ReadVarint(r)
+			continue
+		}
+
+		binary.ReadUvarint(r)
+		n, _ := binary.ReadUvarint(r)
+		if n > binaryMaxRun {
+			t.Fatalf("run of %d cells exceeds binaryMaxRun", n)
+		}
+
+		r.Seek(int64(n+3)/4, io.SeekCurrent)
+		runs++
+	}
+
+	if runs != 3 {
+		t.Fatalf("got %d runs, want 3", runs)
+	}
+}
+
+func TestBinaryNativeRoundTrip(t *testing.T) {
+	for name, cells := range binaryTestCircuits() {
+		var native bytes.Buffer
+		if err := Save(&native, &Circuit{Cells: cells}); err != nil {
+			t.Fatalf("%s: save: %v", name, err)
+		}
+
+		c, err := Load(bytes.NewReader(native.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: load: %v", name, err)
+		}
+
+		var bin bytes.Buffer
+		if err := WriteBinary(&bin, c.Cells); err != nil {
+			t.Fatalf("%s: write binary: %v", name, err)
+		}
+
+		got, err := ReadBinary(&bin)
+		if err != nil {
+			t.Fatalf("%s: read binary: %v", name, err)
+		}
+
+		var out bytes.Buffer
+		if err := Save(&out, &Circuit{Cells: got}); err != nil {
+			t.Fatalf("%s: save: %v", name, err)
+		}
+
+		if !bytes.Equal(out.Bytes(), native.Bytes()) {
+			t.Fatalf("%s: native output differs after binary round trip", name)
+		}
+	}
+}
+
+func TestBinaryTextRoundTrip(t *testing.T) {
+	for name, cells := range binaryTestCircuits() {
+		var text bytes.Buffer
+		if err := WriteText(&text, &Circuit{Cells: cells}); err != nil {
+			t.Fatalf("%s: write text: %v", name, err)
+		}
+
+		c, err := ReadText(bytes.NewReader(text.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: read text: %v", name, err)
+		}
+
+		var bin bytes.Buffer
+		if err := WriteBinary(&bin, c.Cells); err != nil {
+			t.Fatalf("%s: write binary: %v", name, err)
+		}
+
+		got, err := ReadBinary(&bin)
+		if err != nil {
+			t.Fatalf("%s: read binary: %v", name, err)
+		}
+
+		var out bytes.Buffer
+		if err := WriteText(&out, &Circuit{Cells: got}); err != nil {
+			t.Fatalf("%s: write text: %v", name, err)
+		}
+
+		if !bytes.Equal(out.Bytes(), text.Bytes()) {
+			t.Fatalf("%s: text output differs after binary round trip", name)
+		}
+	}
+}
+
+func TestBinaryWriterOrder(t *testing.T) {
+	bw, err := NewBinaryWriter(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bw.Write(5, 5, sim.CellWire); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same cell, earlier row in the same column and earlier column.
+	for _, c := range [][2]int32{{5, 5}, {5, 4}, {4, 9}} {
+		if err := bw.Write(c[0], c[1], sim.CellWire); err == nil {
+			t.Fatalf("cell %d,%d: expected an ordering error", c[0], c[1])
+		}
+	}
+
+	// Empty cells are skipped, even when out of order.
+	if err := bw.Write(0, 0, sim.CellEmpty); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bw.Write(5, 6, 4); err == nil {
+		t.Fatal("expected an error for an invalid state")
+	}
+}
+
+func TestBinaryTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteBinary(&buf, randomCells(3, 1000, 100)); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := decompressBinary(t, buf.Bytes())
+
+	// Cut the record stream at various points, including
+	// just before the end record.
+	for _, n := range []int{0, 1, 2, len(raw) / 2, len(raw) - 1} {
+		_, err := ReadBinary(bytes.NewReader(compressBinary(t, raw[:n])))
+		if err != io.ErrUnexpectedEOF {
+			t.Fatalf("truncated at %d of %d bytes: got %v, want %v", n, len(raw), err, io.ErrUnexpectedEOF)
+		}
+	}
+
+	// A truncated gzip stream fails as well.
+	if _, err := ReadBinary(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); err == nil {
+		t.Fatal("expected an error for a truncated gzip stream")
+	}
+}
+
+func TestBinaryCorrupt(t *testing.T) {
+	tests := map[string][]byte{
+		"kind":   {7},
+		"run":    {binaryColumn, 0, 0, binaryRun, 0, 0},
+		"length": {binaryColumn, 0, 0, binaryRun, 0, 0x81, 0x80, 0x04},
+	}
+
+	for name, raw := range tests {
+		_, err := ReadBinary(bytes.NewReader(compressBinary(t, raw)))
+		if err == nil || err == io.ErrUnexpectedEOF {
+			t.Fatalf("%s: got %v, want a format error", name, err)
+		}
+	}
+
+	// Data after the end record.
+	raw := decompressBinary(t, mustWriteBinary(t, randomCells(7, 10, 10)))
+	if _, err := ReadBinary(bytes.NewReader(compressBinary(t, append(raw, 0)))); err == nil {
+		t.Fatal("expected an error for data after the end record")
+	}
+
+	if _, err := ReadBinary(strings.NewReader("WWB\x02")); err == nil {
+		t.Fatal("expected an error for an unknown version")
+	}
+
+	if _, err := ReadBinary(strings.NewReader("{}")); err == nil {
+		t.Fatal("expected an error for a file which is not binary")
+	}
+}
+
+func TestBinaryTrailer(t *testing.T) {
+	data := mustWriteBinary(t, randomCells(8, 1000, 100))
+
+	// The gzip trailer holds the CRC-32 and size of the data.
+	crc := append([]byte(nil), data...)
+	crc[len(crc)-8] ^= 0xff
+	if _, err := ReadBinary(bytes.NewReader(crc)); err != gzip.ErrChecksum {
+		t.Fatalf("corrupt checksum: got %v, want %v", err, gzip.ErrChecksum)
+	}
+
+	size := append([]byte(nil), data...)
+	size[len(size)-1] ^= 0xff
+	if _, err := ReadBinary(bytes.NewReader(size)); err != gzip.ErrChecksum {
+		t.Fatalf("corrupt size: got %v, want %v", err, gzip.ErrChecksum)
+	}
+
+	if _, err := ReadBinary(bytes.NewReader(data[:len(data)-4])); err != io.ErrUnexpectedEOF {
+		t.Fatalf("truncated trailer: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+// mustWriteBinary returns the given cells in the binary format.
+func mustWriteBinary(t *testing.T, cells sim.CellList) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := WriteBinary(&buf, cells); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// decompressBinary returns the record stream in the given binary file.
+func decompressBinary(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(data[len(binaryMagic):]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+// compressBinary returns a binary file holding the given record stream.
+func compressBinary(t *testing.T, raw []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	buf.Write(binaryMagic)
+
+	gz := gzip.NewWriter(&buf)
+	gz.Write(raw)
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// equalCells returns true if both lists hold the same cells, in the
+// same order. Nil and empty lists are equal.
+func equalCells(a, b sim.CellList) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,216 @@
+// Package circuit defines a circuit document and its file format.
+//
+// A circuit contains the simulation cells, along with any editor data
+// which is stored alongside them, like text annotations.
+package circuit
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+
+	"wireworld/sim"
+	"wireworld/util"
+)
+
+// formatVersion defines the current version of the native file format.
+const formatVersion = 1
+
+// Circuit defines a set of cells, along with its editor data.
+type Circuit struct {
+	Cells       sim.CellList
+	Annotations []Annotation
+	Bookmarks   []Bookmark
+	Meta        map[string]string // Free-form metadata, like the author.
+}
+
+// Bookmark defines a stored camera position.
+type Bookmark struct {
+	Slot int     // Bookmark number.
+	X, Y float64 // Cell coordinates at the center of the viewport.
+	Zoom float64 // Zoom factor.
+}
+
+// Annotation defines a text label which is placed in world coordinates.
+type Annotation struct {
+	X, Y  int32       // Cell coordinates of the top-left corner of the text.
+	Size  float32     // Height of a line of text, in cells.
+	Color color.NRGBA // Text colour.
+	Text  string
+}
+
+// Contains returns true if the given cell coordinates are inside the
+// annotation's bounds. Width is the width of the text in cells.
+func (a *Annotation) Contains(x, y int32, width float32) bool {
+	fx, fy := float32(x-a.X), float32(y-a.Y)
+	return fx >= 0 && fy >= 0 && fx < width && fy < a.Size*float32(len(a.Lines()))
+}
+
+// Lines returns the lines of text in the annotation.
+func (a *Annotation) Lines() []string {
+	return strings.Split(a.Text, "\n")
+}
+
+// file defines the on-disk layout of a circuit in the native format.
+type file struct {
+	Version     int               `json:"version"`
+	Cells       []int32           `json:"cells"`
+	Annotations []fileAnnotation  `json:"annotations,omitempty"`
+	Bookmarks   []fileBookmark    `json:"bookmarks,omitempty"`
+	Meta        map[string]string `json:"meta,omitempty"`
+}
+
+// fileAnnotation defines the on-disk layout of an annotation.
+type fileAnnotation struct {
+	X     int32   `json:"x"`
+	Y     int32   `json:"y"`
+	Size  float32 `json:"size"`
+	Color string  `json:"color"`
+	Text  string  `json:"text"`
+}
+
+// fileBookmark defines the on-disk layout of a camera bookmark.
+type fileBookmark struct {
+	Slot int     `json:"slot"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	Zoom float64 `json:"zoom"`
+}
+
+// Save writes c to w in the native file format.
+// Empty cells are not stored.
+func Save(w io.Writer, c *Circuit) error {
+	f := file{
+		Version: formatVersion,
+		Cells:   c.Cells.Trim(),
+		Meta:    c.Meta,
+	}
+
+	for _, a := range c.Annotations {
+		f.Annotations = append(f.Annotations, fileAnnotation{
+			X:     a.X,
+			Y:     a.Y,
+			Size:  a.Size,
+			Color: util.FormatColor(a.Color),
+			Text:  a.Text,
+		})
+	}
+
+	for _, b := range c.Bookmarks {
+		f.Bookmarks = append(f.Bookmarks, fileBookmark(b))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&f)
+}
+
+// Load reads a circuit in the native file format from r.
+// The returned cell list is sorted.
+func Load(r io.Reader) (*Circuit, error) {
+	var f file
+
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+
+	if f.Version < 1 || f.Version > formatVersion {
+		return nil, fmt.Errorf("unsupported file version %d", f.Version)
+	}
+
+	if len(f.Cells)%3 != 0 {
+		return nil, fmt.Errorf("invalid cell data")
+	}
+
+	c := Circuit{
+		Cells: sim.CellList(f.Cells).Trim(),
+		Meta:  f.Meta,
+	}
+
+	c.Cells.Sort()
+
+	for _, fa := range f.Annotations {
+		clr, err := util.ParseColor(fa.Color)
+		if err != nil {
+			return nil, err
+		}
+
+		c.Annotations = append(c.Annotations, Annotation{
+			X:     fa.X,
+			Y:     fa.Y,
+			Size:  fa.Size,
+			Color: clr,
+			Text:  fa.Text,
+		})
+	}
+
+	for _, fb := range f.Bookmarks {
+		c.Bookmarks = append(c.Bookmarks, Bookmark(fb))
+	}
+
+	return &c, nil
+}
+
+// SaveFile writes c to the given file, in the format implied by its
+// name. Only the native and text formats hold editor data. Images are
+// written with the DefaultFormatOptions.
+func SaveFile(name string, c *Circuit) error {
+	return WriteFile(name, FormatAuto, c, &DefaultFormatOptions)
+}
+
+// LoadFile reads a circuit from the given file. The format is detected
+// from the file name and contents. Images are read with the
+// DefaultFormatOptions.
+func LoadFile(name string) (*Circuit, error) {
+	return ReadFile(name, FormatAuto, &DefaultFormatOptions)
+}
+
+// Crop removes all cells and annotations outside of the given area.
+func (c *Circuit) Crop(area image.Rectangle) {
+	var cells sim.CellList
+	for i := 0; i < len(c.Cells)-2; i += 3 {
+		if image.Pt(int(c.Cells[i]), int(c.Cells[i+1])).In(area) {
+			cells = append(cells, c.Cells[i], c.Cells[i+1], c.Cells[i+2])
+		}
+	}
+
+	var annotations []Annotation
+	for _, a := range c.Annotations {
+		if image.Pt(int(a.X), int(a.Y)).In(area) {
+			annotations = append(annotations, a)
+		}
+	}
+
+	c.Cells = cells
+	c.Annotations = annotations
+}
+
+// Translate moves all cells, annotations and bookmarks by the given offset.
+func (c *Circuit) Translate(dx, dy int32) {
+	for i := 0; i < len(c.Cells)-2; i += 3 {
+		c.Cells[i+0] += dx
+		c.Cells[i+1] += dy
+	}
+
+	for i := range c.Annotations {
+		c.Annotations[i].X += dx
+		c.Annotations[i].Y += dy
+	}
+
+	for i := range c.Bookmarks {
+		c.Bookmarks[i].X += float64(dx)
+		c.Bookmarks[i].Y += float64(dy)
+	}
+}
+
+// ClearSignals turns all electron heads and tails back into wire.
+func (c *Circuit) ClearSignals() {
+	for i := 2; i < len(c.Cells); i += 3 {
+		if c.Cells[i] == sim.CellHead || c.Cells[i] == sim.CellTail {
+			c.Cells[i] = sim.CellWire
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package circuit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"wireworld/sim"
+)
+
+// Format identifies a circuit file format.
+type Format int
+
+// Known file formats.
+const (
+	FormatAuto   Format = iota // Detect the format from the file name or contents.
+	FormatNative               // JSON, with editor data.
+	FormatRLE                  // Extended RLE, as used by Golly.
+	FormatWI                   // Plain text .wi.
+	FormatImage                // PNG image with one pixel per cell. GIF is accepted on input.
+	FormatBinary               // Compact binary format.
+	FormatText                 // Canonical text format, for version control.
+)
+
+// formatNames defines the name of each format, as used by ParseFormat.
+var formatNames = map[Format]string{
+	FormatAuto:   "auto",
+	FormatNative: "native",
+	FormatRLE:    "rle",
+	FormatWI:     "wi",
+	FormatImage:  "png",
+	FormatBinary: "binary",
+	FormatText:   "text",
+}
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	for f, v := range formatNames {
+		if strings.EqualFold(name, v) {
+			return f, nil
+		}
+	}
+	return FormatAuto, fmt.Errorf("unknown format %q", name)
+}
+
+func (f Format) String() string {
+	return formatNames[f]
+}
+
+// FormatOptions defines settings for formats which need them.
+type FormatOptions struct {
+	Colors    ColorMap // Colour for each cell state in images.
+	Tolerance float64  // Largest colour distance accepted when reading images.
+	Scale     int      // Size of a cell in pixels, when writing images.
+}
+
+// DefaultFormatOptions defines the settings used by LoadFile and SaveFile.
+var DefaultFormatOptions = FormatOptions{
+	Colors:    DefaultColorMap,
+	Tolerance: 64,
+	Scale:     1,
+}
+
+// DetectFormat returns the format of a file with the given name. If
+// head is not empty, it holds the leading bytes of the file. Known
+// magic numbers take precedence over the file extension. Unknown files
+// are assumed to be in the native format.
+func DetectFormat(name string, head []byte) Format {
+	switch {
+	case IsBinary(head):
+		return FormatBinary
+	case IsText(head):
+		return FormatText
+	case bytes.HasPrefix(head, []byte("\x89PNG")), bytes.HasPrefix(head, []byte("GIF8")):
+		return FormatImage
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".rle":
+		return FormatRLE
+	case ".wi":
+		return FormatWI
+	case ".png", ".gif":
+		return FormatImage
+	case BinaryExt:
+		return FormatBinary
+	case TextExt:
+		return FormatText
+	case ".json":
+		return FormatNative
+	}
+
+	// RLE files start with comments or their header.
+	if t := bytes.TrimSpace(head); bytes.HasPrefix(t, []byte("#")) || bytes.HasPrefix(t, []byte("x")) {
+		return FormatRLE
+	}
+
+	return FormatNative
+}
+
+// ReadFile reads a circuit from the given file. Only the native and
+// text formats hold editor data. With FormatAuto, the format is
+// detected from the file name and contents.
+func ReadFile(name string, f Format, opt *FormatOptions) (*Circuit, error) {
+	fd, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fd.Close()
+
+	r := bufio.NewReader(fd)
+	if f == FormatAuto {
+		head, _ := r.Peek(512)
+		f = DetectFormat(name, head)
+	}
+
+	var cells sim.CellList
+	switch f {
+	case FormatNative:
+		c, err := Load(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return c, nil
+	case FormatRLE:
+		cells, err = ReadRLE(r)
+	case FormatWI:
+		cells, err = ReadWI(r)
+	case FormatImage:
+		return importImageReader(name, r, opt)
+	case FormatBinary:
+		cells, err = ReadBinary(r)
+	case FormatText:
+		c, err := ReadText(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return c, nil
+	default:
+		err = fmt.Errorf("unsupported format %v", f)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return &Circuit{Cells: cells}, nil
+}
+
+// WriteFile writes a circuit to the given file. Only the native and
+// text formats hold editor data. With FormatAuto, the format is
+// detected from the file name.
+func WriteFile(name string, f Format, c *Circuit, opt *FormatOptions) error {
+	if f == FormatAuto {
+		f = DetectFormat(name, nil)
+	}
+
+	// None of the formats store empty cells. Trim makes a copy,
+	// so sorting it leaves c untouched.
+	cells := c.Cells.Trim()
+	cells.Sort()
+
+	var write func(io.Writer) error
+	switch f {
+	case FormatNative:
+		write = func(w io.Writer) error { return Save(w, c) }
+	case FormatRLE:
+		write = func(w io.Writer) error { return WriteRLE(w, cells) }
+	case FormatWI:
+		write = func(w io.Writer) error { return WriteWI(w, cells) }
+	case FormatImage:
+		return ExportImageFile(name, cells, cells.Bounds(), opt.Scale, opt.Colors)
+	case FormatBinary:
+		write = func(w io.Writer) error { return WriteBinary(w, cells) }
+	case FormatText:
+		write = func(w io.Writer) error { return WriteText(w, c) }
+	default:
+		return fmt.Errorf("unsupported format %v", f)
+	}
+
+	fd, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(fd)
+	if err = write(bw); err == nil {
+		err = bw.Flush()
+	}
+
+	if err != nil {
+		fd.Close()
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	return fd.Close()
+}
+
+// rowOrder returns the indices of all cells in c, sorted by row and
+// then by column. Cell lists themselves are sorted by column.
+func rowOrder(c sim.CellList) []int {
+	rows := make([]int, 0, c.Len())
+	for i := 0; i < len(c)-2; i += 3 {
+		rows = append(rows, i)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if c[a+1] == c[b+1] {
+			return c[a] < c[b]
+		}
+		return c[a+1] < c[b+1]
+	})
+
+	return rows
+}
@@ -0,0 +1,187 @@
+package circuit
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	// Supported image formats for ImportImage.
+	_ "image/gif"
+
+	"wireworld/render"
+	"wireworld/sim"
+	"wireworld/util"
+)
+
+// ColorMap defines the colour which represents each cell state in an image.
+// It is indexed by cell state.
+type ColorMap [4]color.NRGBA
+
+// DefaultColorMap defines the colours commonly used for Wireworld images:
+// black for empty cells, orange for wire, blue for electron heads and
+// white for electron tails.
+var DefaultColorMap = ColorMap{
+	{0x00, 0x00, 0x00, 0xff},
+	{0xff, 0x80, 0x00, 0xff},
+	{0x00, 0x80, 0xff, 0xff},
+	{0xff, 0xff, 0xff, 0xff},
+}
+
+// ParseColorMap parses a colour map from a comma separated list of four
+// colours, one for each cell state, in the form #rrggbb or #rrggbbaa.
+func ParseColorMap(v string) (ColorMap, error) {
+	var cm ColorMap
+
+	fields := strings.Split(v, ",")
+	if len(fields) != len(cm) {
+		return cm, fmt.Errorf("invalid colour map %q: expected %d colours", v, len(cm))
+	}
+
+	for i, f := range fields {
+		c, err := util.ParseColor(strings.TrimSpace(f))
+		if err != nil {
+			return cm, err
+		}
+		cm[i] = c
+	}
+
+	return cm, nil
+}
+
+// String returns the colour map in the form accepted by ParseColorMap.
+func (cm ColorMap) String() string {
+	var set [4]string
+	for i, c := range cm {
+		set[i] = util.FormatColor(c)
+	}
+	return strings.Join(set[:], ",")
+}
+
+// Nearest returns the cell state whose colour is closest to c, along
+// with the euclidean distance between both colours in RGB space.
+func (cm ColorMap) Nearest(c color.Color) (int32, float64) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+
+	state, dist := int32(sim.CellEmpty), math.Inf(1)
+	for i, v := range cm {
+		dr := float64(n.R) - float64(v.R)
+		dg := float64(n.G) - float64(v.G)
+		db := float64(n.B) - float64(v.B)
+
+		if d := math.Sqrt(dr*dr + dg*dg + db*db); d < dist {
+			state, dist = int32(i), d
+		}
+	}
+
+	return state, dist
+}
+
+// ImportImage converts an image with one pixel per cell into a sorted
+// cell list. Each pixel is mapped onto the cell state with the nearest
+// colour in cm. Fully transparent pixels are treated as empty cells.
+//
+// Tolerance defines the largest allowed distance between a pixel and
+// its nearest colour, in RGB space. A pixel outside of the tolerance
+// is an error. A negative tolerance accepts any colour.
+func ImportImage(img image.Image, cm ColorMap, tolerance float64) (sim.CellList, error) {
+	b := img.Bounds()
+	var out sim.CellList
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.At(x, y)
+			if _, _, _, a := c.RGBA(); a == 0 {
+				continue
+			}
+
+			state, dist := cm.Nearest(c)
+			if tolerance >= 0 && dist > tolerance {
+				n := color.NRGBAModel.Convert(c).(color.NRGBA)
+				return nil, fmt.Errorf("pixel %d,%d: colour #%02x%02x%02x does not match any cell state",
+					x, y, n.R, n.G, n.B)
+			}
+
+			if state != sim.CellEmpty {
+				out = append(out, int32(x-b.Min.X), int32(y-b.Min.Y), state)
+			}
+		}
+	}
+
+	out.Sort()
+	return out, nil
+}
+
+// ImportImageFile reads a PNG or GIF image from the given file and
+// converts it into a cell list. Refer to ImportImage for details.
+func ImportImageFile(name string, cm ColorMap, tolerance float64) (sim.CellList, error) {
+	fd, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fd.Close()
+
+	c, err := importImageReader(name, fd, &FormatOptions{Colors: cm, Tolerance: tolerance})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Cells, nil
+}
+
+// importImageReader reads a PNG or GIF image from r and converts it
+// into a circuit. Name is used in error messages.
+func importImageReader(name string, r io.Reader, opt *FormatOptions) (*Circuit, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	cells, err := ImportImage(img, opt.Colors, opt.Tolerance)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return &Circuit{Cells: cells}, nil
+}
+
+// ExportImage draws the cells inside the given area, with each cell
+// covering scale by scale pixels. If the area is empty, the bounds of
+// all non-empty cells are used instead.
+func ExportImage(cells sim.CellList, area image.Rectangle, scale int, cm ColorMap) *image.RGBA {
+	if area.Empty() {
+		area = cells.Bounds()
+	}
+
+	if scale < 1 {
+		scale = 1
+	}
+
+	t := render.Theme{
+		Background: cm[sim.CellEmpty],
+		Cells:      cm,
+	}
+
+	return render.RenderArea(cells, area, float64(scale), render.Options{Theme: &t})
+}
+
+// ExportImageFile writes the cells inside the given area to a PNG file.
+// Refer to ExportImage for details.
+func ExportImageFile(name string, cells sim.CellList, area image.Rectangle, scale int, cm ColorMap) error {
+	fd, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err = png.Encode(fd, ExportImage(cells, area, scale, cm)); err != nil {
+		fd.Close()
+		return err
+	}
+
+	return fd.Close()
+}
@@ -0,0 +1,220 @@
+package circuit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"wireworld/sim"
+)
+
+// rleLineLength defines the largest length of a line of RLE cell data.
+const rleLineLength = 70
+
+// rleStates maps the cell states used by Golly's WireWorld rule onto
+// ours. Golly numbers them empty, head, tail, wire.
+var rleStates = [4]int32{sim.CellEmpty, sim.CellHead, sim.CellTail, sim.CellWire}
+
+// rleTags maps our cell states onto RLE tags.
+var rleTags = [4]byte{'.', 'C', 'A', 'B'}
+
+// ReadRLE reads cells in the extended RLE format used by Golly.
+// The returned list is sorted.
+//
+// Cells are placed relative to the position given by a "#CXRLE Pos=x,y"
+// line, or relative to 0/0 if there is none. A rule other than WireWorld
+// is an error.
+func ReadRLE(r io.Reader) (sim.CellList, error) {
+	var out sim.CellList
+	var ox, oy, x, y int32
+	var header, done bool
+	count := 0
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan() && !done; line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		if strings.HasPrefix(text, "#CXRLE") {
+			for _, f := range strings.Fields(text[6:]) {
+				if strings.HasPrefix(f, "Pos=") {
+					if _, err := fmt.Sscanf(f[4:], "%d,%d", &ox, &oy); err != nil {
+						return nil, fmt.Errorf("line %d: invalid position %q", line, f[4:])
+					}
+				}
+			}
+			continue
+		}
+
+		if len(text) == 0 || text[0] == '#' {
+			continue
+		}
+
+		if !header {
+			if err := rleHeader(text); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			header = true
+			continue
+		}
+
+		for i := 0; i < len(text) && !done; i++ {
+			c := text[i]
+
+			if c >= '0' && c <= '9' {
+				count = count*10 + int(c-'0')
+				continue
+			}
+
+			n := int32(count)
+			if count == 0 {
+				n = 1
+			}
+			count = 0
+
+			switch {
+			case c == ' ' || c == '\t':
+				continue
+			case c == '.' || c == 'b':
+				x += n
+			case c == 'o' || (c >= 'A' && c <= 'C'):
+				state := rleStates[1]
+				if c != 'o' {
+					state = rleStates[c-'A'+1]
+				}
+
+				for ; n > 0; n-- {
+					out = append(out, ox+x, oy+y, state)
+					x++
+				}
+			case c == '$':
+				x = 0
+				y += n
+			case c == '!':
+				done = true
+			default:
+				return nil, fmt.Errorf("line %d: invalid cell state %q", line, c)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !header {
+		return nil, fmt.Errorf("missing RLE header")
+	}
+
+	out.Sort()
+	return out, nil
+}
+
+// rleHeader checks a header line in the form "x = 1, y = 2, rule = WireWorld".
+func rleHeader(text string) error {
+	var key string
+	for _, f := range strings.Split(text, ",") {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			// The topology of a bounded grid, like ":T100,100",
+			// contains a comma itself.
+			if key == "rule" {
+				continue
+			}
+			return fmt.Errorf("invalid header %q", text)
+		}
+
+		key = strings.TrimSpace(kv[0])
+		value := strings.TrimSpace(kv[1])
+
+		switch key {
+		case "x", "y":
+			if _, err := strconv.Atoi(value); err != nil {
+				return fmt.Errorf("invalid header %q", text)
+			}
+		case "rule":
+			// Golly appends the topology to the rule name, like ":T100,100".
+			if i := strings.IndexByte(value, ':'); i > -1 {
+				value = value[:i]
+			}
+
+			if !strings.EqualFold(value, "wireworld") {
+				return fmt.Errorf("unsupported rule %q", value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// WriteRLE writes the given cells in the extended RLE format used by
+// Golly. The position of the top-left cell is stored in a "#CXRLE" line.
+func WriteRLE(w io.Writer, cells sim.CellList) error {
+	b := cells.Bounds()
+	set := cells.Trim()
+
+	// RLE data is stored by row.
+	rows := rowOrder(set)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#CXRLE Pos=%d,%d\n", b.Min.X, b.Min.Y)
+	fmt.Fprintf(bw, "x = %d, y = %d, rule = WireWorld\n", b.Dx(), b.Dy())
+
+	lineLen := 0
+	tag := func(n int, c byte) {
+		t := string(c)
+		if n > 1 {
+			t = strconv.Itoa(n) + t
+		}
+
+		if lineLen+len(t) > rleLineLength {
+			bw.WriteByte('\n')
+			lineLen = 0
+		}
+
+		bw.WriteString(t)
+		lineLen += len(t)
+	}
+
+	// Current position and the run of equal cells being built.
+	x, y := int32(b.Min.X), int32(b.Min.Y)
+	var runState int32
+	runLen := 0
+
+	flush := func() {
+		if runLen > 0 {
+			tag(runLen, rleTags[runState&3])
+			x += int32(runLen)
+			runLen = 0
+		}
+	}
+
+	for _, i := range rows {
+		cx, cy, state := set[i], set[i+1], set[i+2]
+
+		if runLen > 0 && (cy != y || cx != x+int32(runLen) || state != runState) {
+			flush()
+		}
+
+		if cy != y {
+			tag(int(cy-y), '$')
+			x, y = int32(b.Min.X), cy
+		}
+
+		if runLen == 0 {
+			if cx > x {
+				tag(int(cx-x), '.')
+				x = cx
+			}
+			runState = state
+		}
+
+		runLen++
+	}
+
+	flush()
+	tag(1, '!')
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
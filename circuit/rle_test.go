@@ -0,0 +1,237 @@
+package circuit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wireworld/sim"
+)
+
+func TestReadRLEGolly(t *testing.T) {
+	c, err := ReadFile("testdata/golly.rle", FormatAuto, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := sim.CellList{
+		-5, -1, sim.CellHead,
+		-4, -2, sim.CellWire,
+		-4, -1, sim.CellTail,
+		-3, -2, sim.CellWire,
+		-3, -1, sim.CellWire,
+		-2, -1, sim.CellWire,
+		-2, 1, sim.CellHead,
+		0, -1, sim.CellWire,
+	}
+
+	if !equalCells(c.Cells, want) {
+		t.Fatalf("got %v, want %v", c.Cells, want)
+	}
+}
+
+func TestRLEStates(t *testing.T) {
+	tests := map[int32]string{
+		sim.CellWire: "C!",
+		sim.CellHead: "A!",
+		sim.CellTail: "B!",
+	}
+
+	for state, want := range tests {
+		var buf bytes.Buffer
+		if err := WriteRLE(&buf, sim.CellList{0, 0, state}); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if got := lines[len(lines)-1]; got != want {
+			t.Fatalf("state %d: got %q, want %q", state, got, want)
+		}
+	}
+}
+
+func TestRLERoundTrip(t *testing.T) {
+	long := make(sim.CellList, 0, 300)
+	for x := int32(-50); x < 50; x++ {
+		long = append(long, x, 3, sim.CellWire)
+	}
+
+	tests := map[string]sim.CellList{
+		"single": {-7, 12, sim.CellTail},
+		"random": randomCells(4, 2000, 120),
+		"sparse": randomCells(5, 50, 1000),
+		"long":   long,
+	}
+
+	for name, cells := range tests {
+		var buf bytes.Buffer
+		if err := WriteRLE(&buf, cells); err != nil {
+			t.Fatalf("%s: write: %v", name, err)
+		}
+
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if len(line) > rleLineLength {
+				t.Fatalf("%s: line of %d characters", name, len(line))
+			}
+		}
+
+		got, err := ReadRLE(&buf)
+		if err != nil {
+			t.Fatalf("%s: read: %v", name, err)
+		}
+
+		if !equalCells(got, cells) {
+			t.Fatalf("%s: cells differ after round trip", name)
+		}
+	}
+}
+
+func TestReadRLEErrors(t *testing.T) {
+	tests := map[string]string{
+		"header": "#C no header\n",
+		"rule":   "x = 1, y = 1, rule = B3/S23\no!\n",
+		"state":  "x = 1, y = 1, rule = WireWorld\nD!\n",
+		"pos":    "#CXRLE Pos=a,b\nx = 1, y = 1, rule = WireWorld\nA!\n",
+	}
+
+	for name, src := range tests {
+		if _, err := ReadRLE(strings.NewReader(src)); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+
+	// Golly appends the topology to the rule name.
+	src := "x = 1, y = 1, rule = WireWorld:T10,10\nC!\n"
+	if _, err := ReadRLE(strings.NewReader(src)); err != nil {
+		t.Fatalf("rule with topology: %v", err)
+	}
+}
+
+func TestWIRoundTrip(t *testing.T) {
+	for _, cells := range []sim.CellList{
+		{0, 0, sim.CellWire},
+		randomCells(6, 1500, 80),
+	} {
+		var buf bytes.Buffer
+		if err := WriteWI(&buf, cells); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ReadWI(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The .wi format does not store a position.
+		c := Circuit{Cells: append(sim.CellList(nil), cells...)}
+		b := c.Cells.Bounds()
+		c.Translate(int32(-b.Min.X), int32(-b.Min.Y))
+
+		if !equalCells(got, c.Cells) {
+			t.Fatal("cells differ after round trip")
+		}
+	}
+}
+
+func TestReadWI(t *testing.T) {
+	src := "4 2\n #@~\n#  x\n"
+	got, err := ReadWI(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := sim.CellList{
+		0, 1, sim.CellWire,
+		1, 0, sim.CellWire,
+		2, 0, sim.CellHead,
+		3, 0, sim.CellTail,
+	}
+
+	if !equalCells(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want Format
+	}{
+		{"a.rle", "", FormatRLE},
+		{"a.RLE", "", FormatRLE},
+		{"a.wi", "", FormatWI},
+		{"a.png", "", FormatImage},
+		{"a.gif", "", FormatImage},
+		{"a.wwb", "", FormatBinary},
+		{"a.wwt", "", FormatText},
+		{"a.json", "", FormatNative},
+		{"a", "", FormatNative},
+		{"a", "{\"version\": 1}", FormatNative},
+		{"a", "#C comment\nx = 1", FormatRLE},
+		{"a", "x = 1, y = 1", FormatRLE},
+
+		// Magic numbers take precedence over the extension.
+		{"a.json", "WWB\x01", FormatBinary},
+		{"a.rle", "wireworld-text 1\n", FormatText},
+		{"a.wi", "\x89PNG\r\n", FormatImage},
+		{"a", "GIF89a", FormatImage},
+	}
+
+	for _, tt := range tests {
+		if got := DetectFormat(tt.name, []byte(tt.head)); got != tt.want {
+			t.Errorf("DetectFormat(%q, %q): got %v, want %v", tt.name, tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for f, name := range formatNames {
+		got, err := ParseFormat(strings.ToUpper(name))
+		if err != nil || got != f {
+			t.Errorf("ParseFormat(%q): got %v, %v, want %v", name, got, err, f)
+		}
+	}
+
+	if _, err := ParseFormat("mcl"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+// TestFileRoundTrip checks that SaveFile and LoadFile pick the format
+// from the file name, for every format.
+func TestFileRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "circuit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	cells := randomCells(6, 200, 40)
+	for _, ext := range []string{".json", ".rle", ".wi", ".png", BinaryExt, TextExt} {
+		name := filepath.Join(dir, "test"+ext)
+		if err := SaveFile(name, &Circuit{Cells: cells}); err != nil {
+			t.Fatalf("%s: save: %v", ext, err)
+		}
+
+		c, err := LoadFile(name)
+		if err != nil {
+			t.Fatalf("%s: load: %v", ext, err)
+		}
+
+		// Formats without an origin start at 0/0.
+		r := cells.Bounds()
+		if b := c.Cells.Bounds(); b.Min != r.Min {
+			c.Translate(int32(r.Min.X-b.Min.X), int32(r.Min.Y-b.Min.Y))
+		}
+
+		c.Cells.Sort()
+		if !equalCells(c.Cells, cells) {
+			t.Fatalf("%s: cells differ after round trip", ext)
+		}
+	}
+}
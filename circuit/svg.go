@@ -0,0 +1,222 @@
+package circuit
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"sort"
+
+	"wireworld/sim"
+)
+
+// SVGOptions defines how a circuit is drawn as SVG.
+type SVGOptions struct {
+	Area        image.Rectangle // Cells to draw. Defaults to the bounds of all cells.
+	CellSize    float64         // Size of a cell in SVG user units. Defaults to 10.
+	Colors      ColorMap        // Colour for each cell state. Empty cells are the background.
+	Grid        bool            // Draw grid lines between cells?
+	GridColor   color.NRGBA
+	Ports       bool // Mark wire endpoints?
+	PortColor   color.NRGBA
+	Annotations []Annotation // Text labels to draw. Labels outside the area are left out.
+}
+
+// svgRun defines a horizontal run of cells with the same state.
+type svgRun struct {
+	x, y, n int
+	state   int32
+}
+
+// WriteSVG draws the given cells as an SVG image. Horizontal runs of
+// cells with the same state are merged into a single rectangle, to keep
+// the output small.
+//
+// Port markers are drawn on wire cells with exactly one non-empty
+// neighbour. These are the open ends of a wire, where signals enter or
+// leave a component.
+func WriteSVG(w io.Writer, cells sim.CellList, opt *SVGOptions) error {
+	area := opt.Area
+	if area.Empty() {
+		area = cells.Bounds()
+	}
+
+	if area.Empty() {
+		return fmt.Errorf("svg: nothing to draw")
+	}
+
+	cs := opt.CellSize
+	if cs <= 0 {
+		cs = 10
+	}
+
+	bw := bufio.NewWriter(w)
+	p := func(f string, argv ...interface{}) {
+		fmt.Fprintf(bw, f, argv...)
+	}
+
+	p("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	p("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%g\" height=\"%g\" viewBox=\"0 0 %g %g\">\n",
+		float64(area.Dx())*cs, float64(area.Dy())*cs, float64(area.Dx())*cs, float64(area.Dy())*cs)
+
+	p("<rect width=\"100%%\" height=\"100%%\" %s/>\n", svgFill(opt.Colors[sim.CellEmpty]))
+
+	p("<g shape-rendering=\"crispEdges\">\n")
+	for _, r := range svgRuns(cells, area) {
+		p("<rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\" %s/>\n",
+			float64(r.x)*cs, float64(r.y)*cs, float64(r.n)*cs, cs, svgFill(opt.Colors[r.state&3]))
+	}
+	p("</g>\n")
+
+	if opt.Grid {
+		p("<path fill=\"none\" stroke-width=\"%g\" %s d=\"", cs/20, svgStroke(opt.GridColor))
+		for x := 0; x <= area.Dx(); x++ {
+			p("M%g 0V%g", float64(x)*cs, float64(area.Dy())*cs)
+		}
+		for y := 0; y <= area.Dy(); y++ {
+			p("M0 %gH%g", float64(y)*cs, float64(area.Dx())*cs)
+		}
+		p("\"/>\n")
+	}
+
+	if opt.Ports {
+		p("<g fill=\"none\" stroke-width=\"%g\" %s>\n", cs/8, svgStroke(opt.PortColor))
+		for _, pt := range svgPorts(cells, area) {
+			p("<circle cx=\"%g\" cy=\"%g\" r=\"%g\"/>\n",
+				(float64(pt.X)+0.5)*cs, (float64(pt.Y)+0.5)*cs, cs*0.35)
+		}
+		p("</g>\n")
+	}
+
+	for _, a := range opt.Annotations {
+		if !image.Pt(int(a.X), int(a.Y)).In(area) || len(a.Text) == 0 {
+			continue
+		}
+
+		// SVG does not break lines by itself. Each line becomes a
+		// separate row, one line height below the previous one.
+		x := float64(int(a.X)-area.Min.X) * cs
+		p("<text x=\"%g\" y=\"%g\" font-family=\"sans-serif\" font-size=\"%g\" dominant-baseline=\"hanging\" %s>",
+			x, float64(int(a.Y)-area.Min.Y)*cs, float64(a.Size)*cs, svgFill(a.Color))
+
+		for i, line := range a.Lines() {
+			if i == 0 {
+				p("<tspan x=\"%g\">", x)
+			} else {
+				p("<tspan x=\"%g\" dy=\"%g\">", x, float64(a.Size)*cs)
+			}
+			xml.EscapeText(bw, []byte(line))
+			p("</tspan>")
+		}
+
+		p("</text>\n")
+	}
+
+	p("</svg>\n")
+	return bw.Flush()
+}
+
+// SaveSVGFile draws the given cells to an SVG file.
+// Refer to WriteSVG for details.
+func SaveSVGFile(name string, cells sim.CellList, opt *SVGOptions) error {
+	fd, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err = WriteSVG(fd, cells, opt); err != nil {
+		fd.Close()
+		return err
+	}
+
+	return fd.Close()
+}
+
+// svgRuns returns the horizontal runs of non-empty cells inside the
+// given area, relative to the area's top-left corner.
+func svgRuns(cells sim.CellList, area image.Rectangle) []svgRun {
+	var set []svgRun
+	for i := 0; i < len(cells)-2; i += 3 {
+		pt := image.Pt(int(cells[i]), int(cells[i+1]))
+		if cells[i+2] != sim.CellEmpty && pt.In(area) {
+			pt = pt.Sub(area.Min)
+			set = append(set, svgRun{pt.X, pt.Y, 1, cells[i+2]})
+		}
+	}
+
+	// Cell lists are sorted by column. Runs need them by row.
+	sort.Slice(set, func(i, j int) bool {
+		if set[i].y == set[j].y {
+			return set[i].x < set[j].x
+		}
+		return set[i].y < set[j].y
+	})
+
+	var out []svgRun
+	for _, c := range set {
+		if n := len(out) - 1; n >= 0 {
+			last := &out[n]
+			if last.y == c.y && last.state == c.state && last.x+last.n == c.x {
+				last.n++
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+
+	return out
+}
+
+// svgPorts returns the positions of all wire endpoints inside the
+// given area, relative to the area's top-left corner.
+func svgPorts(cells sim.CellList, area image.Rectangle) []image.Point {
+	var out []image.Point
+
+	for i := 0; i < len(cells)-2; i += 3 {
+		x, y := cells[i], cells[i+1]
+		if cells[i+2] == sim.CellEmpty || !image.Pt(int(x), int(y)).In(area) {
+			continue
+		}
+
+		n := 0
+		for dy := int32(-1); dy <= 1; dy++ {
+			for dx := int32(-1); dx <= 1; dx++ {
+				if dx == 0 && dy == 0 {
+					continue
+				}
+				if j := cells.IndexOf(x+dx, y+dy); j > -1 && cells[j+2] != sim.CellEmpty {
+					n++
+				}
+			}
+		}
+
+		if n == 1 {
+			out = append(out, image.Pt(int(x), int(y)).Sub(area.Min))
+		}
+	}
+
+	return out
+}
+
+// svgFill returns the fill attributes for colour c.
+func svgFill(c color.NRGBA) string {
+	return svgPaint("fill", c)
+}
+
+// svgStroke returns the stroke attributes for colour c.
+func svgStroke(c color.NRGBA) string {
+	return svgPaint("stroke", c)
+}
+
+// svgPaint returns the attributes for the given paint property.
+// Colour values are assumed to be non-premultiplied.
+func svgPaint(name string, c color.NRGBA) string {
+	v := fmt.Sprintf("%s=\"#%02x%02x%02x\"", name, c.R, c.G, c.B)
+	if c.A < 0xff {
+		v += fmt.Sprintf(" %s-opacity=\"%.3g\"", name, float64(c.A)/255)
+	}
+	return v
+}
@@ -0,0 +1,285 @@
+package circuit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"wireworld/sim"
+	"wireworld/util"
+)
+
+// TextExt defines the file extension for the text format.
+const TextExt = ".wwt"
+
+// textVersion defines the current version of the text format.
+const textVersion = 1
+
+// textMagic starts the first line of a file in the text format.
+const textMagic = "wireworld-text"
+
+// textChunkShift defines the size of a chunk in the text format,
+// as a power of two.
+const textChunkShift = 5
+
+// textChars maps cell states onto the characters of the text format.
+var textChars = [4]byte{'.', '#', '@', '~'}
+
+// IsText returns true if the given leading bytes of a file identify
+// the text format.
+func IsText(head []byte) bool {
+	return bytes.HasPrefix(head, []byte(textMagic+" "))
+}
+
+// WriteText writes c to w in the canonical text format. This format is
+// meant to be kept under version control: identical circuits always
+// produce identical output, and small edits lead to small, readable
+// changes in the text.
+//
+// The first line identifies the format and its version. It is followed
+// by metadata, bookmarks and annotations, one per line, and then the
+// cells. Lines starting with '#' are comments and are ignored.
+// Bookmarks are sorted by slot, annotations by position and text.
+//
+//	wireworld-text 1
+//	meta author "Jane Doe"
+//	bookmark 1 12.5 -3 8
+//	annotation 10 -4 2 #000000ff "Clock"
+//	chunk 0 0
+//	|..##@~##
+//	|
+//	|.....#
+//
+// Cells are stored in chunks of 32 by 32 cells, in the order defined by
+// sim.CellList. Each chunk starts with the coordinates of its top-left
+// cell, followed by one line per row of cells, each starting with '|'.
+// Rows hold '.' for empty cells, '#' for wire, '@' for electron heads
+// and '~' for electron tails. Trailing empty cells and rows are left out.
+func WriteText(w io.Writer, c *Circuit) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s %d\n", textMagic, textVersion)
+
+	keys := make([]string, 0, len(c.Meta))
+	for k := range c.Meta {
+		if len(k) == 0 || strings.ContainsAny(k, " \t\r\n\"") {
+			return fmt.Errorf("invalid metadata key %q", k)
+		}
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(bw, "meta %s %s\n", k, strconv.Quote(c.Meta[k]))
+	}
+
+	bookmarks := append([]Bookmark(nil), c.Bookmarks...)
+	sort.SliceStable(bookmarks, func(i, j int) bool { return bookmarks[i].Slot < bookmarks[j].Slot })
+
+	for _, b := range bookmarks {
+		fmt.Fprintf(bw, "bookmark %d %s %s %s\n", b.Slot, formatFloat(b.X, 64),
+			formatFloat(b.Y, 64), formatFloat(b.Zoom, 64))
+	}
+
+	annotations := append([]Annotation(nil), c.Annotations...)
+	sort.Slice(annotations, func(i, j int) bool {
+		a, b := &annotations[i], &annotations[j]
+		switch {
+		case a.Y != b.Y:
+			return a.Y < b.Y
+		case a.X != b.X:
+			return a.X < b.X
+		case a.Text != b.Text:
+			return a.Text < b.Text
+		case a.Size != b.Size:
+			return a.Size < b.Size
+		}
+		return util.FormatColor(a.Color) < util.FormatColor(b.Color)
+	})
+
+	for _, a := range annotations {
+		fmt.Fprintf(bw, "annotation %d %d %s %s %s\n", a.X, a.Y, formatFloat(float64(a.Size), 32),
+			util.FormatColor(a.Color), strconv.Quote(a.Text))
+	}
+
+	// Gather the cells by chunk.
+	type chunkKey struct{ x, y int32 }
+	const size = 1 << textChunkShift
+
+	chunks := make(map[chunkKey]*[size][size]uint8)
+	var order []chunkKey
+
+	for i := 0; i < len(c.Cells)-2; i += 3 {
+		x, y, state := c.Cells[i], c.Cells[i+1], c.Cells[i+2]
+		if state == sim.CellEmpty {
+			continue
+		}
+
+		k := chunkKey{x >> textChunkShift, y >> textChunkShift}
+		ch, ok := chunks[k]
+		if !ok {
+			ch = new([size][size]uint8)
+			chunks[k] = ch
+			order = append(order, k)
+		}
+
+		ch[y-k.y<<textChunkShift][x-k.x<<textChunkShift] = uint8(state & 3)
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].x == order[j].x {
+			return order[i].y < order[j].y
+		}
+		return order[i].x < order[j].x
+	})
+
+	var row []byte
+	for _, k := range order {
+		ch := chunks[k]
+		fmt.Fprintf(bw, "chunk %d %d\n", k.x<<textChunkShift, k.y<<textChunkShift)
+
+		last := size - 1
+		for last > 0 && ch[last] == [size]uint8{} {
+			last--
+		}
+
+		for y := 0; y <= last; y++ {
+			row = append(row[:0], '|')
+			for _, v := range ch[y] {
+				row = append(row, textChars[v])
+			}
+
+			bw.Write(bytes.TrimRight(row, "."))
+			bw.WriteByte('\n')
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadText reads a circuit in the text format from r.
+// Refer to WriteText for details. The returned cell list is sorted.
+func ReadText(r io.Reader) (*Circuit, error) {
+	var c Circuit
+	var header, chunk bool
+	var cx, cy, row int32
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+
+		if line == 1 {
+			var version int
+			if _, err := fmt.Sscanf(text, textMagic+" %d", &version); err != nil {
+				return nil, fmt.Errorf("not a text circuit file")
+			}
+
+			if version < 1 || version > textVersion {
+				return nil, fmt.Errorf("unsupported file version %d", version)
+			}
+
+			header = true
+			continue
+		}
+
+		if len(text) == 0 || text[0] == '#' {
+			continue
+		}
+
+		if text[0] == '|' {
+			if !chunk {
+				return nil, fmt.Errorf("line %d: row outside of a chunk", line)
+			}
+
+			for x, ch := range []byte(text[1:]) {
+				state := bytes.IndexByte(textChars[:], ch)
+				if state < 0 {
+					return nil, fmt.Errorf("line %d: invalid cell %q", line, ch)
+				}
+
+				if state != sim.CellEmpty {
+					c.Cells = append(c.Cells, cx+int32(x), cy+row, int32(state))
+				}
+			}
+
+			row++
+			continue
+		}
+
+		var err error
+		fields := strings.Fields(text)
+
+		switch fields[0] {
+		case "chunk":
+			_, err = fmt.Sscanf(text, "chunk %d %d", &cx, &cy)
+			chunk = true
+			row = 0
+
+		case "meta":
+			var key, value string
+			if _, err = fmt.Sscanf(text, "meta %s %q", &key, &value); err == nil {
+				if c.Meta == nil {
+					c.Meta = make(map[string]string)
+				}
+				c.Meta[key] = value
+			}
+
+		case "bookmark":
+			var b Bookmark
+			if _, err = fmt.Sscanf(text, "bookmark %d %g %g %g", &b.Slot, &b.X, &b.Y, &b.Zoom); err == nil {
+				c.Bookmarks = append(c.Bookmarks, b)
+			}
+
+		case "annotation":
+			var a Annotation
+			var clr string
+			if _, err = fmt.Sscanf(text, "annotation %d %d %g %s %q", &a.X, &a.Y, &a.Size, &clr, &a.Text); err == nil {
+				if a.Color, err = util.ParseColor(clr); err == nil {
+					c.Annotations = append(c.Annotations, a)
+				}
+			}
+
+		default:
+			err = fmt.Errorf("unknown keyword %q", fields[0])
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !header {
+		return nil, fmt.Errorf("not a text circuit file")
+	}
+
+	c.Cells.Sort()
+	return &c, nil
+}
+
+// formatFloat formats v with the fewest digits which represent it exactly.
+func formatFloat(v float64, bits int) string {
+	return strconv.FormatFloat(v, 'g', -1, bits)
+}
+
+// SaveTextFile writes c to the given file in the text format.
+func SaveTextFile(name string, c *Circuit) error {
+	fd, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err = WriteText(fd, c); err != nil {
+		fd.Close()
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	return fd.Close()
+}
@@ -0,0 +1,168 @@
+package circuit
+
+import (
+	"bytes"
+	"image/color"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"wireworld/sim"
+)
+
+// textTestCircuit returns a circuit with cells and all kinds of editor data.
+func textTestCircuit() *Circuit {
+	return &Circuit{
+		Cells: randomCells(4, 3000, 300),
+		Meta: map[string]string{
+			"author": "Jane \"JD\" Doe",
+			"title":  "Clock\nand counter",
+			"empty":  "",
+		},
+		Bookmarks: []Bookmark{
+			{Slot: 3, X: -12.5, Y: 7, Zoom: 0.25},
+			{Slot: 1, X: 1e9, Y: -1e-3, Zoom: 16},
+			{Slot: 9, X: 0, Y: 0, Zoom: 1},
+		},
+		Annotations: []Annotation{
+			{X: 10, Y: -4, Size: 2, Color: color.NRGBA{0, 0, 0, 0xff}, Text: "Clock"},
+			{X: -3, Y: 8, Size: 1.5, Color: color.NRGBA{0xff, 0x80, 0, 0x80}, Text: "Two\nlines"},
+			{X: 10, Y: -4, Size: 2, Color: color.NRGBA{0, 0, 0, 0xff}, Text: "Alarm"},
+			{X: 2, Y: -4, Size: 1, Color: color.NRGBA{0x10, 0x20, 0x30, 0x40}, Text: "\"quoted\""},
+		},
+	}
+}
+
+func TestTextDeterministic(t *testing.T) {
+	c := textTestCircuit()
+
+	var want bytes.Buffer
+	if err := WriteText(&want, c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shuffle everything which has no inherent order.
+	rng := rand.New(rand.NewSource(5))
+	for i := 0; i < 10; i++ {
+		s := *c
+		s.Bookmarks = append([]Bookmark(nil), c.Bookmarks...)
+		s.Annotations = append([]Annotation(nil), c.Annotations...)
+		s.Meta = make(map[string]string)
+		for k, v := range c.Meta {
+			s.Meta[k] = v
+		}
+
+		rng.Shuffle(len(s.Bookmarks), func(i, j int) {
+			s.Bookmarks[i], s.Bookmarks[j] = s.Bookmarks[j], s.Bookmarks[i]
+		})
+		rng.Shuffle(len(s.Annotations), func(i, j int) {
+			s.Annotations[i], s.Annotations[j] = s.Annotations[j], s.Annotations[i]
+		})
+
+		var got bytes.Buffer
+		if err := WriteText(&got, &s); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Fatalf("shuffle %d: output differs:\n%s\nwant:\n%s", i, got.Bytes(), want.Bytes())
+		}
+	}
+
+	// The input must be left untouched.
+	if !reflect.DeepEqual(c, textTestCircuit()) {
+		t.Fatal("WriteText modified its input")
+	}
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	c := textTestCircuit()
+
+	var first bytes.Buffer
+	if err := WriteText(&first, c); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadText(bytes.NewReader(first.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalCells(got.Cells, c.Cells) {
+		t.Fatal("cells differ after round trip")
+	}
+
+	if !reflect.DeepEqual(got.Meta, c.Meta) {
+		t.Fatalf("meta: got %v, want %v", got.Meta, c.Meta)
+	}
+
+	if len(got.Bookmarks) != len(c.Bookmarks) || len(got.Annotations) != len(c.Annotations) {
+		t.Fatalf("got %d bookmarks and %d annotations, want %d and %d", len(got.Bookmarks),
+			len(got.Annotations), len(c.Bookmarks), len(c.Annotations))
+	}
+
+	for _, b := range c.Bookmarks {
+		if !containsBookmark(got.Bookmarks, b) {
+			t.Fatalf("bookmark %+v is missing", b)
+		}
+	}
+
+	for _, a := range c.Annotations {
+		if !containsAnnotation(got.Annotations, a) {
+			t.Fatalf("annotation %+v is missing", a)
+		}
+	}
+
+	// Writing the circuit again produces the same text.
+	var second bytes.Buffer
+	if err := WriteText(&second, got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(second.Bytes(), first.Bytes()) {
+		t.Fatalf("output differs after round trip:\n%s\nwant:\n%s", second.Bytes(), first.Bytes())
+	}
+}
+
+func TestTextEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteText(&buf, &Circuit{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ReadText(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Cells.Len() != 0 || len(c.Bookmarks) != 0 || len(c.Annotations) != 0 {
+		t.Fatalf("got %+v, want an empty circuit", c)
+	}
+}
+
+func TestTextInvalidMeta(t *testing.T) {
+	for _, k := range []string{"", "two words", "quo\"te"} {
+		c := &Circuit{Meta: map[string]string{k: "x"}, Cells: sim.CellList{0, 0, sim.CellWire}}
+		if err := WriteText(&bytes.Buffer{}, c); err == nil {
+			t.Fatalf("key %q: expected an error", k)
+		}
+	}
+}
+
+func containsBookmark(list []Bookmark, b Bookmark) bool {
+	for _, v := range list {
+		if v == b {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAnnotation(list []Annotation, a Annotation) bool {
+	for _, v := range list {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
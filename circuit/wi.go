@@ -0,0 +1,82 @@
+package circuit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"wireworld/sim"
+)
+
+// wiChars maps cell states onto the characters of the .wi format.
+var wiChars = [4]byte{' ', '#', '@', '~'}
+
+// ReadWI reads cells in the plain text .wi format. The returned list is
+// sorted. The first line may hold the width and height of the circuit,
+// followed by one line of text per row of cells: '#' for wire, '@' for
+// electron heads and '~' for electron tails. Any other character is an
+// empty cell.
+func ReadWI(r io.Reader) (sim.CellList, error) {
+	var out sim.CellList
+
+	scanner := bufio.NewScanner(r)
+	for y := int32(0); scanner.Scan(); y++ {
+		text := scanner.Text()
+
+		// The dimensions are redundant. Skip them.
+		var w, h int
+		if y == 0 {
+			if n, _ := fmt.Sscanf(text, "%d %d", &w, &h); n == 2 {
+				y--
+				continue
+			}
+		}
+
+		for x, c := range []byte(text) {
+			for state, v := range wiChars {
+				if state != sim.CellEmpty && c == v {
+					out = append(out, int32(x), y, int32(state))
+				}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	out.Sort()
+	return out, nil
+}
+
+// WriteWI writes the given cells in the plain text .wi format. Refer to
+// ReadWI for details. Cell positions are relative to the top-left corner
+// of the circuit bounds.
+func WriteWI(w io.Writer, cells sim.CellList) error {
+	b := cells.Bounds()
+	set := cells.Trim()
+	rows := rowOrder(set)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%d %d\n", b.Dx(), b.Dy())
+
+	line := []byte(strings.Repeat(" ", b.Dx()))
+	next := 0
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for i := range line {
+			line[i] = ' '
+		}
+
+		for ; next < len(rows) && int(set[rows[next]+1]) == y; next++ {
+			n := rows[next]
+			line[int(set[n])-b.Min.X] = wiChars[set[n+2]&3]
+		}
+
+		bw.Write(line)
+		bw.WriteByte('\n')
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"time"
+
+	"wireworld/circuit"
+	"wireworld/diff"
+	"wireworld/record"
+	"wireworld/render"
+	"wireworld/sim"
+)
+
+// Command defines a subcommand which runs without a window.
+type Command struct {
+	Name  string
+	Usage string                  // Short description of the command.
+	Run   func(args []string) int // Runs the command and returns the exit code.
+}
+
+// commands defines all known subcommands.
+var commands = []*Command{
+	{Name: "convert", Usage: "Convert a circuit between file formats.", Run: runConvert},
+	{Name: "diff", Usage: "Compare two circuits cell by cell.", Run: runDiff},
+	{Name: "patch", Usage: "Apply a patch created by diff to a circuit.", Run: runPatch},
+	{Name: "record", Usage: "Record a simulation run to an animated GIF or PNG sequence.", Run: runRecord},
+	{Name: "render", Usage: "Render a circuit to a PNG image, or compare it with one.", Run: runRender},
+}
+
+// findCommand returns the subcommand with the given name, or nil
+// if there is none.
+func findCommand(name string) *Command {
+	for _, c := range commands {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// loadCells reads the cells from a circuit file in any supported format.
+// The format is detected from the file name and contents.
+func loadCells(name string, colors circuit.ColorMap, tolerance float64) (sim.CellList, error) {
+	c, err := circuit.ReadFile(name, circuit.FormatAuto, &circuit.FormatOptions{
+		Colors:    colors,
+		Tolerance: tolerance,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Cells, nil
+}
+
+// parseArea parses a rectangle in the form "x,y,w,h".
+func parseArea(v string) (image.Rectangle, error) {
+	var x, y, w, h int
+	if _, err := fmt.Sscanf(v, "%d,%d,%d,%d", &x, &y, &w, &h); err != nil {
+		return image.Rectangle{}, fmt.Errorf("invalid area %q: expected x,y,w,h", v)
+	}
+	return image.Rect(x, y, x+w, y+h), nil
+}
+
+// runConvert reads a circuit in one format and writes it in another,
+// optionally transforming it on the way.
+func runConvert(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("usage: %s convert [options] -in <input> -out <output>\n", os.Args[0])
+		fmt.Println("Formats are detected from file names and contents, unless given")
+		fmt.Println("explicitly. Known formats: native, text, rle, wi, png, binary.")
+		fmt.Println("Empty cells are never written, in any format.")
+		fs.PrintDefaults()
+	}
+
+	colors := circuit.DefaultColorMap
+	in := fs.String("in", "", "Input file.")
+	out := fs.String("out", "", "Output file.")
+	from := fs.String("from", "auto", "Format of the input file.")
+	to := fs.String("to", "auto", "Format of the output file.")
+	crop := fs.String("crop", "", "Keep only the cells inside the area x,y,w,h.")
+	reset := fs.Bool("reset", false, "Turn electron heads and tails back into wire.")
+	normalize := fs.Bool("normalize", false, "Move the circuit so its top-left cell is at 0,0.")
+	tolerance := fs.Float64("tolerance", 64, "Largest colour distance accepted for image input.")
+	scale := fs.Int("scale", 1, "Size of a cell, in pixels, for image output.")
+	fs.Var(&colorMapFlag{&colors}, "colors", "Colours for the empty/wire/head/tail states in images.")
+	fs.Parse(args)
+
+	// Allow plain positional arguments as well.
+	if len(*in) == 0 && len(*out) == 0 && fs.NArg() == 2 {
+		*in, *out = fs.Arg(0), fs.Arg(1)
+	}
+
+	if len(*in) == 0 || len(*out) == 0 || *scale < 1 {
+		fs.Usage()
+		return 1
+	}
+
+	inFormat, err := circuit.ParseFormat(*from)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	outFormat, err := circuit.ParseFormat(*to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	opt := circuit.FormatOptions{
+		Colors:    colors,
+		Tolerance: *tolerance,
+		Scale:     *scale,
+	}
+
+	c, err := circuit.ReadFile(*in, inFormat, &opt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if len(*crop) > 0 {
+		area, err := parseArea(*crop)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		c.Crop(area)
+	}
+
+	if *reset {
+		c.ClearSignals()
+	}
+
+	if *normalize {
+		b := c.Cells.Bounds()
+		c.Translate(int32(-b.Min.X), int32(-b.Min.Y))
+	}
+
+	if err := circuit.WriteFile(*out, outFormat, c, &opt); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// runDiff compares two circuits and reports the differences. Like
+// diff(1), it exits with 0 if they are equal, 1 if they differ and 2
+// if something went wrong.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("usage: %s diff [options] <old> <new>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	quiet := fs.Bool("q", false, "Only print the summary line.")
+	imageFile := fs.String("image", "", "Write an overlay image of the differences to this PNG file.")
+	patchFile := fs.String("patch", "", "Write a patch which turns old into new to this file.")
+	scale := fs.Int("scale", 4, "Size of a cell, in pixels, in the overlay image.")
+	theme := fs.String("theme", "light", "Name of a built-in colour theme for the overlay image.")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	t := render.FindTheme(*theme)
+	if t == nil {
+		fmt.Fprintf(os.Stderr, "unknown theme %q\n", *theme)
+		return 2
+	}
+
+	a, err := loadCells(fs.Arg(0), circuit.DefaultColorMap, 0)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	b, err := loadCells(fs.Arg(1), circuit.DefaultColorMap, 0)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	changes := diff.Compare(a, b)
+
+	if *quiet {
+		added, removed, changed := diff.Count(changes)
+		fmt.Printf("%d added, %d removed, %d changed\n", added, removed, changed)
+	} else if err := diff.WriteReport(os.Stdout, changes); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if len(*imageFile) > 0 {
+		img := diff.Overlay(a, b, changes, image.Rectangle{}, *scale, t)
+		if err := writePNG(*imageFile, img); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	if len(*patchFile) > 0 {
+		if err := diff.SavePatchFile(*patchFile, changes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	if len(changes) > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// runPatch applies a patch to a circuit and writes the result. Editor
+// data in native and text files is kept.
+func runPatch(args []string) int {
+	fs := flag.NewFlagSet("patch", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("usage: %s patch [options] <input> <patch> <output>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	to := fs.String("to", "auto", "Format of the output file.")
+	fs.Parse(args)
+
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return 1
+	}
+
+	outFormat, err := circuit.ParseFormat(*to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	opt := circuit.FormatOptions{Colors: circuit.DefaultColorMap, Scale: 1}
+	c, err := circuit.ReadFile(fs.Arg(0), circuit.FormatAuto, &opt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	changes, err := diff.LoadPatchFile(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if c.Cells, err = diff.Apply(c.Cells, changes); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", fs.Arg(1), err)
+		return 1
+	}
+
+	if err := circuit.WriteFile(fs.Arg(2), outFormat, c, &opt); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// runRecord runs a circuit for a number of generations, and records
+// each generation as a frame.
+func runRecord(args []string) int {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("usage: %s record [options] <input> <output>\n", os.Args[0])
+		fmt.Println("Output ending in .gif produces an animated GIF, anything else a PNG")
+		fmt.Println("sequence. PNG names may contain a frame number verb like frame-%05d.png.")
+		fs.PrintDefaults()
+	}
+
+	colors := circuit.DefaultColorMap
+	frames := fs.Int("frames", 100, "Number of generations to record.")
+	area := fs.String("area", "", "Area of cells to record as x,y,w,h. Defaults to the circuit bounds.")
+	scale := fs.Int("scale", 4, "Size of a cell, in pixels.")
+	delay := fs.Duration("delay", 100*time.Millisecond, "Time between frames of an animated GIF.")
+	tolerance := fs.Float64("tolerance", 64, "Largest colour distance accepted for image input.")
+	fs.Var(&colorMapFlag{&colors}, "colors", "Colours for the empty/wire/head/tail states.")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 || *frames < 1 || *scale < 1 {
+		fs.Usage()
+		return 1
+	}
+
+	cells, err := loadCells(fs.Arg(0), colors, *tolerance)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	rect := cells.Bounds()
+	if len(*area) > 0 {
+		if rect, err = parseArea(*area); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	rec, err := record.New(fs.Arg(1), rect, float64(*scale), colors, *delay)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	sim.Replace(cells)
+
+	for i := 0; i < *frames; i++ {
+		if i > 0 {
+			sim.Step(true)
+		}
+
+		if err := rec.Add(sim.Cells()); err == record.ErrFull {
+			fmt.Fprintf(os.Stderr, "%v: stopped after %d frames\n", err, rec.Frames())
+			break
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if err := rec.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// runRender draws a circuit with the software renderer and writes it to
+// a PNG file. With -compare, the result is compared with an existing
+// image instead, which makes it usable for golden image tests.
+func runRender(args []string) int {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("usage: %s render [options] <input> <output.png>\n", os.Args[0])
+		fmt.Printf("       %s render [options] -compare <input> <golden.png>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	area := fs.String("area", "", "Area of cells to render as x,y,w,h. Defaults to the circuit bounds.")
+	zoom := fs.Float64("zoom", 10, "Size of a cell, in pixels.")
+	grid := fs.Bool("grid", false, "Draw grid lines.")
+	theme := fs.String("theme", "light", "Name of a built-in colour theme.")
+	steps := fs.Int("steps", 0, "Number of simulation steps to perform before rendering.")
+	compare := fs.Bool("compare", false, "Compare with the output file instead of writing it.")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 || *zoom <= 0 {
+		fs.Usage()
+		return 1
+	}
+
+	t := render.FindTheme(*theme)
+	if t == nil {
+		fmt.Fprintf(os.Stderr, "unknown theme %q\n", *theme)
+		return 1
+	}
+
+	cells, err := loadCells(fs.Arg(0), circuit.DefaultColorMap, 0)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	rect := cells.Bounds()
+	if len(*area) > 0 {
+		if rect, err = parseArea(*area); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if rect.Empty() {
+		fmt.Fprintln(os.Stderr, "nothing to render")
+		return 1
+	}
+
+	sim.Replace(cells)
+	for i := 0; i < *steps; i++ {
+		sim.Step(true)
+	}
+
+	img := render.RenderArea(sim.Cells(), rect, *zoom, render.Options{
+		Theme: t,
+		Grid:  *grid,
+	})
+
+	if !*compare {
+		if err := writePNG(fs.Arg(1), img); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	golden, err := readImage(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if n := render.Diff(img, golden); n > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d pixels differ\n", fs.Arg(1), n)
+		return 1
+	}
+
+	return 0
+}
+
+// writePNG writes img to the given PNG file.
+func writePNG(name string, img image.Image) error {
+	fd, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err = png.Encode(fd, img); err != nil {
+		fd.Close()
+		return err
+	}
+
+	return fd.Close()
+}
+
+// readImage reads an image from the given file.
+func readImage(name string) (image.Image, error) {
+	fd, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fd.Close()
+
+	img, _, err := image.Decode(fd)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return img, nil
+}
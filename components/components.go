@@ -1,45 +1,72 @@
 // Package components contains some predefined wireworld components
 // which can be loaded into a simulation.
 //
-// Each component defines a list of cells. Each cell is composed
-// of 3 integers: X and Y cell cooridnates and the cell state.
-// Cell states can have one of the following values:
+// Each component is defined as ASCII art, which is parsed when the
+// program starts. Refer to Parse for the syntax. Each component is
+// exposed as a list of cells, along with its ports. The list of cells
+// is composed of 3 integers per cell: X and Y cell cooridnates
+// and the cell state. Cell states can have one of the following values:
 //
 //   0: empty
 //   1: wire
 //   2: electron head
 //   3: electron tail
 //
-// Empty cells (state 0) can be omitted altogether. They just take
+// Empty cells (state 0) are omitted altogether. They just take
 // up unnecessary space.
 //
 // ref: https://www.quinapalus.com/wi-index.html
 package components
 
+// Predefined components, as parsed from their definitions.
+var (
+	clock4 = mustParse(`
+		.H.
+		#.t
+		.#.
+	`)
+
+	diode = mustParse(`
+		...##..
+		tH#.##o
+		...##..
+	`)
+
+	or = mustParse(`
+		i##....
+		...#...
+		..####o
+		...#...
+		i##....
+	`)
+
+	xor = mustParse(`
+		i##......
+		...#.....
+		..####...
+		..#..###o
+		..####...
+		...#.....
+		i##......
+	`)
+)
+
 // Clock4 defines a clock with a 4-cycle interval.
-var Clock4 = []int32{1, 0, 2, 0, 1, 1, 2, 1, 3, 1, 2, 1}
+var Clock4 = clock4.Cells
 
 // Diode defines a 1-way wire.
-var Diode = []int32{
-	3, 0, 1, 4, 0, 1,
-	0, 1, 3, 1, 1, 2,
-	2, 1, 1, 4, 1, 1,
-	5, 1, 1, 6, 1, 1,
-	3, 2, 1, 4, 2, 1,
-}
+var Diode = diode.Cells
 
 // OR defines an OR gate.
-var OR = []int32{
-	0, 0, 1, 1, 0, 1, 2, 0, 1, 3, 1, 1,
-	2, 2, 1, 3, 2, 1, 4, 2, 1, 5, 2, 1, 6, 2, 1,
-	3, 3, 1, 0, 4, 1, 1, 4, 1, 2, 4, 1,
-}
+var OR = or.Cells
 
 // XOR defines an exclusive-OR gate.
-var XOR = []int32{
-	0, 0, 1, 1, 0, 1, 2, 0, 1, 3, 1, 1,
-	2, 2, 1, 3, 2, 1, 4, 2, 1, 5, 2, 1,
-	2, 3, 1, 5, 3, 1, 6, 3, 1, 7, 3, 1, 8, 3, 1,
-	2, 4, 1, 3, 4, 1, 4, 4, 1, 5, 4, 1,
-	3, 5, 1, 0, 6, 1, 1, 6, 1, 2, 6, 1,
-}
+var XOR = xor.Cells
+
+// Ports of each predefined component. Clock4 has none.
+var (
+	Clock4Ports = clock4.Ports
+	DiodePorts  = diode.Ports
+	ORPorts     = or.Ports
+	XORPorts    = xor.Ports
+)
@@ -0,0 +1,79 @@
+package components
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestComponents compares the parsed components with the cell lists
+// they were originally defined as.
+func TestComponents(t *testing.T) {
+	tests := []struct {
+		name  string
+		cells []int32
+		want  []int32
+		ports []Port
+	}{
+		{
+			"Clock4", Clock4,
+			[]int32{1, 0, 2, 0, 1, 1, 2, 1, 3, 1, 2, 1},
+			nil,
+		},
+		{
+			"Diode", Diode,
+			[]int32{
+				3, 0, 1, 4, 0, 1,
+				0, 1, 3, 1, 1, 2,
+				2, 1, 1, 4, 1, 1,
+				5, 1, 1, 6, 1, 1,
+				3, 2, 1, 4, 2, 1,
+			},
+			[]Port{{X: 6, Y: 1, Output: true}},
+		},
+		{
+			"OR", OR,
+			[]int32{
+				0, 0, 1, 1, 0, 1, 2, 0, 1, 3, 1, 1,
+				2, 2, 1, 3, 2, 1, 4, 2, 1, 5, 2, 1, 6, 2, 1,
+				3, 3, 1, 0, 4, 1, 1, 4, 1, 2, 4, 1,
+			},
+			[]Port{{X: 0, Y: 0}, {X: 6, Y: 2, Output: true}, {X: 0, Y: 4}},
+		},
+		{
+			"XOR", XOR,
+			[]int32{
+				0, 0, 1, 1, 0, 1, 2, 0, 1, 3, 1, 1,
+				2, 2, 1, 3, 2, 1, 4, 2, 1, 5, 2, 1,
+				2, 3, 1, 5, 3, 1, 6, 3, 1, 7, 3, 1, 8, 3, 1,
+				2, 4, 1, 3, 4, 1, 4, 4, 1, 5, 4, 1,
+				3, 5, 1, 0, 6, 1, 1, 6, 1, 2, 6, 1,
+			},
+			[]Port{{X: 0, Y: 0}, {X: 8, Y: 3, Output: true}, {X: 0, Y: 6}},
+		},
+	}
+
+	ports := map[string][]Port{
+		"Clock4": Clock4Ports,
+		"Diode":  DiodePorts,
+		"OR":     ORPorts,
+		"XOR":    XORPorts,
+	}
+
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.cells, tt.want) {
+			t.Errorf("%s: got cells %v, want %v", tt.name, tt.cells, tt.want)
+		}
+
+		if got := ports[tt.name]; !reflect.DeepEqual(got, tt.ports) {
+			t.Errorf("%s: got ports %v, want %v", tt.name, got, tt.ports)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{"#x#", "##\n#?"} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("%q: expected an error", src)
+		}
+	}
+}
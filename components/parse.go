@@ -0,0 +1,74 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"wireworld/sim"
+)
+
+// Component defines a predefined set of cells, along with the points
+// where signals enter or leave it.
+type Component struct {
+	Cells []int32 // X, Y and state of each non-empty cell, ordered by row.
+	Ports []Port
+}
+
+// Port defines a wire cell where a component connects to other circuitry.
+type Port struct {
+	X, Y   int32
+	Output bool // Signals leave the component here? Otherwise, they enter.
+}
+
+// Parse parses a component from its ASCII art definition. Each line of
+// text defines a row of cells, using one character per cell:
+//
+//	.  empty
+//	#  wire
+//	H  electron head
+//	t  electron tail
+//	i  wire, marked as an input port
+//	o  wire, marked as an output port
+//
+// Leading and trailing whitespace on each line is ignored, as are blank
+// lines before and after the definition. This allows definitions to be
+// indented along with the surrounding source code.
+func Parse(src string) (*Component, error) {
+	var c Component
+
+	lines := strings.Split(strings.TrimSpace(src), "\n")
+	for y, line := range lines {
+		for x, ch := range strings.TrimSpace(line) {
+			state := sim.CellWire
+			switch ch {
+			case '.':
+				continue
+			case '#':
+			case 'H':
+				state = sim.CellHead
+			case 't':
+				state = sim.CellTail
+			case 'i':
+				c.Ports = append(c.Ports, Port{X: int32(x), Y: int32(y)})
+			case 'o':
+				c.Ports = append(c.Ports, Port{X: int32(x), Y: int32(y), Output: true})
+			default:
+				return nil, fmt.Errorf("line %d: invalid cell %q", y+1, ch)
+			}
+
+			c.Cells = append(c.Cells, int32(x), int32(y), int32(state))
+		}
+	}
+
+	return &c, nil
+}
+
+// mustParse is like Parse, but panics if the definition is invalid.
+// It is meant for the predefined components.
+func mustParse(src string) *Component {
+	c, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
@@ -1,53 +1,449 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
+
+	"wireworld/circuit"
+	"wireworld/record"
 )
 
+// maxRecentFiles defines the number of recently used files
+// which are remembered.
+const maxRecentFiles = 10
+
 // Config defines application settings.
 type Config struct {
-	Width      uint
-	Height     uint
-	Fullscreen bool
+	Width        uint
+	Height       uint
+	X, Y         int  // Window position.
+	Positioned   bool // Window position is known?
+	Fullscreen   bool
+	File         string        // Circuit file to load and save.
+	Theme        string        // Name of the colour theme.
+	ThemeFile    string        // File with additional colour themes.
+	KeymapFile   string        // File with user key bindings.
+	StepInterval time.Duration // Time between simulation steps.
+	GridVisible  bool
+	InfoVisible  bool
+	Recent       []string // Recently used circuit files, most recent first.
+
+	AutosaveInterval time.Duration // Time between recovery snapshots. Zero disables them.
+	AutosaveKeep     int           // Largest number of recovery snapshots to keep.
+
+	ImageColors    circuit.ColorMap // Colour for each cell state in images.
+	ImageTolerance float64          // Largest colour distance accepted on image import.
+	ImageScale     int              // Size of a cell, in pixels, on image export.
+	RecordFormat   string           // Output format for recordings: gif or png.
+
+	path     string          // File the configuration is loaded from and saved to.
+	defaults *Config         // Settings before the configuration file was read.
+	stored   *Config         // Settings as read from the configuration file.
+	initial  *Config         // Settings at startup, including commandline arguments.
+	flags    map[string]bool // Names of the flags set on the commandline.
+}
+
+// configDir returns the directory which holds the user's configuration
+// files for this application. Returns an empty string if it can not be
+// determined.
+func configDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, AppName)
 }
 
 // ParseArgs parses commandline arguments and returns a config struct.
-// Exits the program with an error if invalid data was found.
+// Settings are first read from the user's configuration file, if it
+// exists. Commandline arguments override those.
+// Exits the program with an error if invalid arguments were found.
+// Invalid settings in the configuration file are reported and replaced
+// by their defaults.
 func ParseArgs() *Config {
 	var c Config
 	c.Width = 1280
 	c.Height = 800
 	c.Fullscreen = false
+	c.Theme = "light"
+	c.StepInterval = 50 * time.Millisecond
+	c.GridVisible = true
+	c.InfoVisible = true
+	c.ImageColors = circuit.DefaultColorMap
+	c.ImageTolerance = 64
+	c.ImageScale = 1
+	c.RecordFormat = record.FormatGIF
+	c.AutosaveInterval = time.Minute
+	c.AutosaveKeep = 5
+
+	if dir := configDir(); len(dir) > 0 {
+		c.ThemeFile = filepath.Join(dir, "themes.json")
+		c.KeymapFile = filepath.Join(dir, "keymap.json")
+		c.path = filepath.Join(dir, "config.json")
+	}
+
+	defaults := c
+
+	// A broken configuration file should not keep the program from
+	// starting. Report it and carry on with the defaults.
+	if err := c.load(); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, err)
+	}
 
 	flag.Usage = func() {
-		fmt.Printf("usage: %s [options]\n", os.Args[0])
+		fmt.Printf("usage: %s [options] [file]\n", os.Args[0])
+		fmt.Printf("       %s <command> [options] [args]\n", os.Args[0])
 		flag.PrintDefaults()
+
+		fmt.Println("\ncommands:")
+		for _, cmd := range commands {
+			fmt.Printf("  %-10s %s\n", cmd.Name, cmd.Usage)
+		}
 	}
 
 	flag.UintVar(&c.Width, "width", c.Width, "Display width in pixels.")
 	flag.UintVar(&c.Height, "height", c.Height, "Display height in pixels.")
 	flag.BoolVar(&c.Fullscreen, "fullscreen", c.Fullscreen, "Use a fullscreen or windowed display.")
+	flag.StringVar(&c.Theme, "theme", c.Theme, "Name of the colour theme to use.")
+	flag.StringVar(&c.ThemeFile, "themes", c.ThemeFile, "JSON file with additional colour themes.")
+	flag.StringVar(&c.KeymapFile, "keymap", c.KeymapFile, "JSON file with user key bindings.")
+	flag.DurationVar(&c.StepInterval, "interval", c.StepInterval, "Time between simulation steps.")
+	flag.Var(&colorMapFlag{&c.ImageColors}, "imagecolors", "Colours for the empty/wire/head/tail states in images.")
+	flag.Float64Var(&c.ImageTolerance, "imagetolerance", c.ImageTolerance, "Largest colour distance accepted on image import. Negative accepts any colour.")
+	flag.IntVar(&c.ImageScale, "imagescale", c.ImageScale, "Size of a cell, in pixels, on image export.")
+	flag.StringVar(&c.RecordFormat, "recordformat", c.RecordFormat, "Output format for recordings: gif or png.")
+	flag.DurationVar(&c.AutosaveInterval, "autosave", c.AutosaveInterval, "Time between recovery snapshots. Zero disables them.")
+	flag.IntVar(&c.AutosaveKeep, "autosavekeep", c.AutosaveKeep, "Largest number of recovery snapshots to keep.")
 	version := flag.Bool("version", false, "Displays version information.")
+
+	// Commandline arguments only apply to this session. Remember the
+	// stored settings, so Save can tell them apart from changes made
+	// through the UI.
+	stored := c
 	flag.Parse()
+	initial := c
+	c.defaults, c.stored, c.initial = &defaults, &stored, &initial
+
+	c.flags = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { c.flags[f.Name] = true })
+
+	c.File = flag.Arg(0)
 
 	if *version {
 		fmt.Println(Version())
 		os.Exit(0)
 	}
 
-	if c.Width == 0 {
-		fmt.Fprintf(os.Stderr, "width should be > 0")
-		flag.Usage()
-		os.Exit(1)
-	}
+	// A broken configuration file should not keep the program from
+	// starting either, so only invalid arguments are fatal.
+	check := func(ok bool, name, msg string, reset func()) {
+		if ok {
+			return
+		}
+
+		if c.fromFlag(name) {
+			fmt.Fprintln(os.Stderr, msg)
+			flag.Usage()
+			os.Exit(1)
+		}
 
-	if c.Height == 0 {
-		fmt.Fprintf(os.Stderr, "height should be > 0")
-		flag.Usage()
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "%s: %s; using the default\n", c.path, msg)
+		reset()
 	}
 
+	check(c.Width > 0, "width", "width should be > 0", func() { c.Width = defaults.Width })
+	check(c.Height > 0, "height", "height should be > 0", func() { c.Height = defaults.Height })
+	check(c.RecordFormat == record.FormatGIF || c.RecordFormat == record.FormatPNG,
+		"recordformat", "recordformat should be gif or png", func() { c.RecordFormat = defaults.RecordFormat })
+	check(c.ImageScale > 0, "imagescale", "imagescale should be > 0", func() { c.ImageScale = defaults.ImageScale })
+	check(c.AutosaveKeep > 0, "autosavekeep", "autosavekeep should be > 0", func() { c.AutosaveKeep = defaults.AutosaveKeep })
+
 	return &c
 }
+
+// fromFlag returns true if the named setting was given on the
+// commandline, rather than read from the configuration file.
+func (c *Config) fromFlag(name string) bool {
+	return c.flags[name]
+}
+
+// colorMapFlag implements flag.Value for a colour map.
+type colorMapFlag struct {
+	cm *circuit.ColorMap
+}
+
+func (f *colorMapFlag) String() string {
+	if f.cm == nil {
+		return circuit.DefaultColorMap.String()
+	}
+	return f.cm.String()
+}
+
+func (f *colorMapFlag) Set(v string) error {
+	cm, err := circuit.ParseColorMap(v)
+	if err != nil {
+		return err
+	}
+
+	*f.cm = cm
+	return nil
+}
+
+// AddRecent marks the given file as the most recently used one.
+func (c *Config) AddRecent(file string) {
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+
+	recent := []string{file}
+	for _, v := range c.Recent {
+		if v != file && len(recent) < maxRecentFiles {
+			recent = append(recent, v)
+		}
+	}
+
+	c.Recent = recent
+}
+
+// configFile defines the on-disk layout of the configuration file.
+// Pointer fields are left out if they are not set.
+type configFile struct {
+	Width        uint     `json:"width"`
+	Height       uint     `json:"height"`
+	X            *int     `json:"x,omitempty"`
+	Y            *int     `json:"y,omitempty"`
+	Fullscreen   bool     `json:"fullscreen"`
+	Theme        string   `json:"theme"`
+	ThemeFile    string   `json:"themeFile"`
+	KeymapFile   string   `json:"keymapFile"`
+	StepInterval string   `json:"stepInterval"`
+	Grid         bool     `json:"grid"`
+	Panel        bool     `json:"panel"`
+	Recent       []string `json:"recent"`
+
+	ImageColors    string  `json:"imageColors"`
+	ImageTolerance float64 `json:"imageTolerance"`
+	ImageScale     int     `json:"imageScale"`
+	RecordFormat   string  `json:"recordFormat"`
+
+	AutosaveInterval string `json:"autosaveInterval"`
+	AutosaveKeep     int    `json:"autosaveKeep"`
+}
+
+// load reads settings from the configuration file. Settings which are
+// missing from the file keep their current value.
+func (c *Config) load() error {
+	if len(c.path) == 0 {
+		return nil
+	}
+
+	fd, err := os.Open(c.path)
+	if err != nil {
+		return err
+	}
+
+	defer fd.Close()
+
+	f := configFile{
+		Width:        c.Width,
+		Height:       c.Height,
+		Fullscreen:   c.Fullscreen,
+		Theme:        c.Theme,
+		ThemeFile:    c.ThemeFile,
+		KeymapFile:   c.KeymapFile,
+		StepInterval: c.StepInterval.String(),
+		Grid:         c.GridVisible,
+		Panel:        c.InfoVisible,
+
+		ImageColors:    c.ImageColors.String(),
+		ImageTolerance: c.ImageTolerance,
+		ImageScale:     c.ImageScale,
+		RecordFormat:   c.RecordFormat,
+
+		AutosaveInterval: c.AutosaveInterval.String(),
+		AutosaveKeep:     c.AutosaveKeep,
+	}
+
+	if err := json.NewDecoder(fd).Decode(&f); err != nil {
+		return fmt.Errorf("%s: %v", c.path, err)
+	}
+
+	colors, err := circuit.ParseColorMap(f.ImageColors)
+	if err != nil {
+		return fmt.Errorf("%s: %v", c.path, err)
+	}
+
+	interval, err := time.ParseDuration(f.StepInterval)
+	if err != nil {
+		return fmt.Errorf("%s: %v", c.path, err)
+	}
+
+	autosave, err := time.ParseDuration(f.AutosaveInterval)
+	if err != nil {
+		return fmt.Errorf("%s: %v", c.path, err)
+	}
+
+	if f.Width > 0 && f.Height > 0 {
+		c.Width = f.Width
+		c.Height = f.Height
+	}
+
+	if f.X != nil && f.Y != nil {
+		c.X, c.Y = *f.X, *f.Y
+		c.Positioned = true
+	}
+
+	c.Fullscreen = f.Fullscreen
+	c.Theme = f.Theme
+	c.ThemeFile = f.ThemeFile
+	c.KeymapFile = f.KeymapFile
+	c.StepInterval = interval
+	c.GridVisible = f.Grid
+	c.InfoVisible = f.Panel
+	c.Recent = f.Recent
+	c.ImageColors = colors
+	c.ImageTolerance = f.ImageTolerance
+	c.RecordFormat = f.RecordFormat
+	c.AutosaveInterval = autosave
+
+	if f.ImageScale > 0 {
+		c.ImageScale = f.ImageScale
+	}
+
+	if f.AutosaveKeep > 0 {
+		c.AutosaveKeep = f.AutosaveKeep
+	}
+
+	return nil
+}
+
+// keepStored resets the settings in f which have not been changed
+// since startup to their stored values. This keeps commandline
+// arguments out of the configuration file.
+func (c *Config) keepStored(f *configFile) {
+	s, i := c.stored, c.initial
+
+	if c.Width == i.Width && c.Height == i.Height {
+		f.Width, f.Height = s.Width, s.Height
+	}
+
+	if c.X == i.X && c.Y == i.Y && c.Positioned == i.Positioned {
+		f.X, f.Y = nil, nil
+		if s.Positioned {
+			f.X, f.Y = &s.X, &s.Y
+		}
+	}
+
+	if c.Fullscreen == i.Fullscreen {
+		f.Fullscreen = s.Fullscreen
+	}
+
+	if c.Theme == i.Theme {
+		f.Theme = s.Theme
+	}
+
+	if c.ThemeFile == i.ThemeFile {
+		f.ThemeFile = s.ThemeFile
+	}
+
+	if c.KeymapFile == i.KeymapFile {
+		f.KeymapFile = s.KeymapFile
+	}
+
+	if c.StepInterval == i.StepInterval {
+		f.StepInterval = s.StepInterval.String()
+	}
+
+	if c.GridVisible == i.GridVisible {
+		f.Grid = s.GridVisible
+	}
+
+	if c.InfoVisible == i.InfoVisible {
+		f.Panel = s.InfoVisible
+	}
+
+	if c.ImageColors == i.ImageColors {
+		f.ImageColors = s.ImageColors.String()
+	}
+
+	if c.ImageTolerance == i.ImageTolerance {
+		f.ImageTolerance = s.ImageTolerance
+	}
+
+	if c.ImageScale == i.ImageScale {
+		f.ImageScale = s.ImageScale
+	}
+
+	if c.RecordFormat == i.RecordFormat {
+		f.RecordFormat = s.RecordFormat
+	}
+
+	if c.AutosaveInterval == i.AutosaveInterval {
+		f.AutosaveInterval = s.AutosaveInterval.String()
+	}
+
+	if c.AutosaveKeep == i.AutosaveKeep {
+		f.AutosaveKeep = s.AutosaveKeep
+	}
+}
+
+// Save writes the settings to the configuration file, creating its
+// directory if needed. Settings given on the commandline are only
+// written if they were changed through the UI afterwards.
+func (c *Config) Save() error {
+	if len(c.path) == 0 {
+		return nil
+	}
+
+	f := configFile{
+		Width:        c.Width,
+		Height:       c.Height,
+		Fullscreen:   c.Fullscreen,
+		Theme:        c.Theme,
+		ThemeFile:    c.ThemeFile,
+		KeymapFile:   c.KeymapFile,
+		StepInterval: c.StepInterval.String(),
+		Grid:         c.GridVisible,
+		Panel:        c.InfoVisible,
+		Recent:       c.Recent,
+
+		ImageColors:    c.ImageColors.String(),
+		ImageTolerance: c.ImageTolerance,
+		ImageScale:     c.ImageScale,
+		RecordFormat:   c.RecordFormat,
+
+		AutosaveInterval: c.AutosaveInterval.String(),
+		AutosaveKeep:     c.AutosaveKeep,
+	}
+
+	if c.Positioned {
+		f.X, f.Y = &c.X, &c.Y
+	}
+
+	if c.stored != nil {
+		c.keepStored(&f)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+
+	fd, err := os.Create(c.path)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(fd)
+	enc.SetIndent("", "  ")
+
+	if err = enc.Encode(&f); err != nil {
+		fd.Close()
+		return err
+	}
+
+	return fd.Close()
+}
@@ -0,0 +1,208 @@
+// Package diff compares two circuits cell by cell. The differences can
+// be written as a textual report, drawn as an overlay image, or stored
+// as a patch which turns the old circuit into the new one.
+package diff
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+
+	"wireworld/render"
+	"wireworld/sim"
+)
+
+// Kind defines the kind of difference in a single cell.
+type Kind int32
+
+// Known kinds of differences. Their values double as indices into Colors.
+const (
+	Added   Kind = iota + 1 // Cell only exists in the new circuit.
+	Removed                 // Cell only exists in the old circuit.
+	Changed                 // Cell exists in both, with a different state.
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	default:
+		return fmt.Sprintf("Kind(%d)", int32(k))
+	}
+}
+
+// Colors defines the colour in which each kind of difference is
+// highlighted. Colours are non-premultiplied.
+var Colors = [4]color.NRGBA{
+	{},
+	{0x00, 0xc0, 0x00, 0xc0},
+	{0xe0, 0x00, 0x00, 0xc0},
+	{0xff, 0xc0, 0x00, 0xc0},
+}
+
+// stateNames defines a name for each cell state.
+var stateNames = [4]string{"empty", "wire", "head", "tail"}
+
+// Change defines the difference in a single cell.
+type Change struct {
+	X, Y     int32
+	Kind     Kind
+	From, To int32 // Cell state in the old and new circuit.
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %d,%d %s", c.X, c.Y, stateNames[c.To&3])
+	case Removed:
+		return fmt.Sprintf("- %d,%d %s", c.X, c.Y, stateNames[c.From&3])
+	default:
+		return fmt.Sprintf("~ %d,%d %s -> %s", c.X, c.Y, stateNames[c.From&3], stateNames[c.To&3])
+	}
+}
+
+// Compare returns the differences between the old circuit a and the new
+// circuit b. Both lists must be sorted. Empty cells are treated as if
+// they did not exist. The changes are returned in cell list order.
+func Compare(a, b sim.CellList) []Change {
+	return compare(a, b, false)
+}
+
+// CompareWires is like Compare, but treats electron heads and tails as
+// wire. Only cells which were added or removed are reported, so running
+// signals do not show up as changes.
+func CompareWires(a, b sim.CellList) []Change {
+	return compare(a, b, true)
+}
+
+// compare implements Compare and CompareWires.
+func compare(a, b sim.CellList, wiresOnly bool) []Change {
+	var out []Change
+	i, j := 0, 0
+
+	for i < len(a)-2 || j < len(b)-2 {
+		// Skip empty cells on either side.
+		if i < len(a)-2 && a[i+2] == sim.CellEmpty {
+			i += 3
+			continue
+		}
+
+		if j < len(b)-2 && b[j+2] == sim.CellEmpty {
+			j += 3
+			continue
+		}
+
+		switch {
+		case j >= len(b)-2 || (i < len(a)-2 && less(a[i], a[i+1], b[j], b[j+1])):
+			out = append(out, Change{X: a[i], Y: a[i+1], Kind: Removed, From: a[i+2]})
+			i += 3
+
+		case i >= len(a)-2 || less(b[j], b[j+1], a[i], a[i+1]):
+			out = append(out, Change{X: b[j], Y: b[j+1], Kind: Added, To: b[j+2]})
+			j += 3
+
+		default:
+			if a[i+2] != b[j+2] && !wiresOnly {
+				out = append(out, Change{X: a[i], Y: a[i+1], Kind: Changed, From: a[i+2], To: b[j+2]})
+			}
+			i += 3
+			j += 3
+		}
+	}
+
+	return out
+}
+
+// less returns true if cell ax/ay comes before bx/by in a sorted cell list.
+func less(ax, ay, bx, by int32) bool {
+	if ax == bx {
+		return ay < by
+	}
+	return ax < bx
+}
+
+// Count returns the number of changes of each kind.
+func Count(changes []Change) (added, removed, changed int) {
+	for _, c := range changes {
+		switch c.Kind {
+		case Added:
+			added++
+		case Removed:
+			removed++
+		case Changed:
+			changed++
+		}
+	}
+	return
+}
+
+// Find returns the change for the given cell, or nil if it did not
+// change. The changes must be in cell list order, as returned by Compare.
+func Find(changes []Change, x, y int32) *Change {
+	n := sort.Search(len(changes), func(i int) bool {
+		return !less(changes[i].X, changes[i].Y, x, y)
+	})
+
+	if n < len(changes) && changes[n].X == x && changes[n].Y == y {
+		return &changes[n]
+	}
+
+	return nil
+}
+
+// Cells returns the changed cells as a cell list, with the kind of
+// change in place of the cell state.
+func Cells(changes []Change) sim.CellList {
+	out := make(sim.CellList, 0, len(changes)*3)
+	for _, c := range changes {
+		out = append(out, c.X, c.Y, int32(c.Kind))
+	}
+	return out
+}
+
+// Bounds returns the smallest rectangle which encloses all changes.
+func Bounds(changes []Change) image.Rectangle {
+	return Cells(changes).Bounds()
+}
+
+// WriteReport writes a human-readable description of the changes to w:
+// a summary line, followed by one line per changed cell.
+func WriteReport(w io.Writer, changes []Change) error {
+	added, removed, changed := Count(changes)
+	if _, err := fmt.Fprintf(w, "%d added, %d removed, %d changed\n", added, removed, changed); err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Overlay draws the new circuit b with the given theme, and highlights
+// the changes on top of it in Colors. Removed cells are highlighted in
+// place. If the area is empty, the bounds of both circuits are used.
+func Overlay(a, b sim.CellList, changes []Change, area image.Rectangle, scale int, t *render.Theme) *image.RGBA {
+	if area.Empty() {
+		area = a.Bounds().Union(b.Bounds())
+	}
+
+	if scale < 1 {
+		scale = 1
+	}
+
+	return render.RenderArea(b, area, float64(scale), render.Options{
+		Theme:         t,
+		Overlay:       Cells(changes),
+		OverlayColors: Colors,
+	})
+}
@@ -0,0 +1,71 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"wireworld/sim"
+)
+
+// testOld and testNew differ in every possible way.
+var (
+	testOld = sim.CellList{
+		0, 0, sim.CellWire,
+		0, 1, sim.CellHead,
+		1, 0, sim.CellWire,
+		2, 2, sim.CellTail,
+		3, 0, sim.CellEmpty,
+		5, 5, sim.CellWire,
+	}
+
+	testNew = sim.CellList{
+		0, 0, sim.CellWire,
+		0, 1, sim.CellTail,
+		2, 2, sim.CellWire,
+		3, 0, sim.CellHead,
+		4, -1, sim.CellWire,
+		5, 5, sim.CellWire,
+	}
+)
+
+func TestCompare(t *testing.T) {
+	want := []Change{
+		{X: 0, Y: 1, Kind: Changed, From: sim.CellHead, To: sim.CellTail},
+		{X: 1, Y: 0, Kind: Removed, From: sim.CellWire},
+		{X: 2, Y: 2, Kind: Changed, From: sim.CellTail, To: sim.CellWire},
+		{X: 3, Y: 0, Kind: Added, To: sim.CellHead},
+		{X: 4, Y: -1, Kind: Added, To: sim.CellWire},
+	}
+
+	got := Compare(testOld, testNew)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if added, removed, changed := Count(got); added != 2 || removed != 1 || changed != 2 {
+		t.Fatalf("Count: got %d, %d, %d, want 2, 1, 2", added, removed, changed)
+	}
+
+	if got := Compare(testNew, testNew); len(got) != 0 {
+		t.Fatalf("identical circuits: got %v, want no changes", got)
+	}
+}
+
+func TestCompareWires(t *testing.T) {
+	want := []Change{
+		{X: 1, Y: 0, Kind: Removed, From: sim.CellWire},
+		{X: 3, Y: 0, Kind: Added, To: sim.CellHead},
+		{X: 4, Y: -1, Kind: Added, To: sim.CellWire},
+	}
+
+	if got := CompareWires(testOld, testNew); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// Only signals differ.
+	a := sim.CellList{0, 0, sim.CellHead, 1, 0, sim.CellTail, 2, 0, sim.CellWire}
+	b := sim.CellList{0, 0, sim.CellWire, 1, 0, sim.CellHead, 2, 0, sim.CellTail}
+	if got := CompareWires(a, b); len(got) != 0 {
+		t.Fatalf("signal changes: got %v, want none", got)
+	}
+}
@@ -0,0 +1,171 @@
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"wireworld/sim"
+)
+
+// patchHeader identifies a patch file, including its version.
+const patchHeader = "wireworld-patch 1"
+
+// WritePatch writes the changes to w as a patch. Each change is a line
+// starting with '+' for added, '-' for removed or '~' for changed cells,
+// followed by the cell coordinates, the old state unless the cell was
+// added, and the new state unless it was removed.
+//
+// States are stored for removed cells as well, so Apply can detect
+// patches which do not match the circuit they are applied to.
+func WritePatch(w io.Writer, changes []Change) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, patchHeader)
+
+	for _, c := range changes {
+		switch c.Kind {
+		case Added:
+			fmt.Fprintf(bw, "+ %d %d %d\n", c.X, c.Y, c.To)
+		case Removed:
+			fmt.Fprintf(bw, "- %d %d %d\n", c.X, c.Y, c.From)
+		case Changed:
+			fmt.Fprintf(bw, "~ %d %d %d %d\n", c.X, c.Y, c.From, c.To)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadPatch reads a patch written by WritePatch.
+func ReadPatch(r io.Reader) ([]Change, error) {
+	var out []Change
+
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != patchHeader {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("not a patch file")
+	}
+
+	for line := 2; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 {
+			continue
+		}
+
+		var c Change
+		var n int
+		var err error
+
+		switch text[0] {
+		case '+':
+			c.Kind = Added
+			n, err = fmt.Sscanf(text[1:], "%d %d %d", &c.X, &c.Y, &c.To)
+		case '-':
+			c.Kind = Removed
+			n, err = fmt.Sscanf(text[1:], "%d %d %d", &c.X, &c.Y, &c.From)
+		case '~':
+			c.Kind = Changed
+			n, err = fmt.Sscanf(text[1:], "%d %d %d %d", &c.X, &c.Y, &c.From, &c.To)
+		default:
+			return nil, fmt.Errorf("line %d: unknown change %q", line, text[:1])
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		if n < 3 || c.From < 0 || c.From > 3 || c.To < 0 || c.To > 3 ||
+			(c.Kind == Added) != (c.From == sim.CellEmpty) ||
+			(c.Kind == Removed) != (c.To == sim.CellEmpty) {
+			return nil, fmt.Errorf("line %d: invalid change", line)
+		}
+
+		out = append(out, c)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Apply applies the changes to a copy of the sorted cell list, and
+// returns the sorted result. It is an error if a change does not match
+// the cells: a cell to be added already exists or is added twice, or a
+// cell to be removed or changed has a different state than expected.
+func Apply(cells sim.CellList, changes []Change) (sim.CellList, error) {
+	out := make(sim.CellList, len(cells))
+	copy(out, cells)
+
+	var added sim.CellList
+	seen := make(map[[2]int32]bool)
+	for _, c := range changes {
+		state := int32(sim.CellEmpty)
+		n := out.IndexOf(c.X, c.Y)
+		if n > -1 {
+			state = out[n+2]
+		}
+
+		if state != c.From {
+			return nil, fmt.Errorf("cell %d,%d: expected %s, found %s",
+				c.X, c.Y, stateNames[c.From&3], stateNames[state&3])
+		}
+
+		switch c.Kind {
+		case Added:
+			if seen[[2]int32{c.X, c.Y}] {
+				return nil, fmt.Errorf("cell %d,%d: added more than once", c.X, c.Y)
+			}
+			seen[[2]int32{c.X, c.Y}] = true
+
+			// Appending now would break the sort order which
+			// IndexOf relies on.
+			added = append(added, c.X, c.Y, c.To)
+		case Removed:
+			out[n+2] = sim.CellEmpty
+		case Changed:
+			out[n+2] = c.To
+		}
+	}
+
+	out = append(out, added...).Trim()
+	out.Sort()
+	return out, nil
+}
+
+// LoadPatchFile reads a patch from the given file.
+func LoadPatchFile(name string) ([]Change, error) {
+	fd, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fd.Close()
+
+	changes, err := ReadPatch(fd)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return changes, nil
+}
+
+// SavePatchFile writes the changes to the given file as a patch.
+func SavePatchFile(name string, changes []Change) error {
+	fd, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err = WritePatch(fd, changes); err != nil {
+		fd.Close()
+		return err
+	}
+
+	return fd.Close()
+}
@@ -0,0 +1,117 @@
+package diff
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"wireworld/sim"
+)
+
+// randomCells returns a sorted list of n random cells inside a square
+// of the given size. Some of them are empty.
+func randomCells(rng *rand.Rand, n int, size int32) sim.CellList {
+	seen := make(map[[2]int32]bool)
+
+	var out sim.CellList
+	for len(seen) < n {
+		x, y := rng.Int31n(size), rng.Int31n(size)
+		if !seen[[2]int32{x, y}] {
+			seen[[2]int32{x, y}] = true
+			out = append(out, x, y, rng.Int31n(4))
+		}
+	}
+
+	out.Sort()
+	return out
+}
+
+func TestPatchRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20; i++ {
+		a := randomCells(rng, 300, 30)
+		b := randomCells(rng, 300, 30)
+
+		var buf bytes.Buffer
+		if err := WritePatch(&buf, Compare(a, b)); err != nil {
+			t.Fatal(err)
+		}
+
+		changes, err := ReadPatch(&buf)
+		if err != nil {
+			t.Fatalf("circuit %d: %v", i, err)
+		}
+
+		got, err := Apply(a, changes)
+		if err != nil {
+			t.Fatalf("circuit %d: %v", i, err)
+		}
+
+		if want := b.Trim(); !equalCells(got, want) {
+			t.Fatalf("circuit %d: patched cells differ from the target", i)
+		}
+	}
+}
+
+func TestApplyMismatch(t *testing.T) {
+	cells := sim.CellList{0, 0, sim.CellWire, 1, 0, sim.CellHead}
+
+	tests := map[string][]Change{
+		"changed": {{X: 1, Y: 0, Kind: Changed, From: sim.CellTail, To: sim.CellWire}},
+		"removed": {{X: 0, Y: 0, Kind: Removed, From: sim.CellHead}},
+		"missing": {{X: 5, Y: 5, Kind: Removed, From: sim.CellWire}},
+		"exists":  {{X: 0, Y: 0, Kind: Added, To: sim.CellWire}},
+		"twice": {
+			{X: 2, Y: 0, Kind: Added, To: sim.CellWire},
+			{X: 2, Y: 0, Kind: Added, To: sim.CellHead},
+		},
+	}
+
+	for name, changes := range tests {
+		if _, err := Apply(cells, changes); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// The input is left untouched.
+	if cells[2] != sim.CellWire || cells[5] != sim.CellHead {
+		t.Fatalf("Apply modified its input: %v", cells)
+	}
+}
+
+func TestReadPatchErrors(t *testing.T) {
+	tests := map[string]string{
+		"header":  "wireworld-patch 2\n+ 0 0 1\n",
+		"empty":   "",
+		"kind":    patchHeader + "\n* 0 0 1\n",
+		"short":   patchHeader + "\n+ 0 0\n",
+		"number":  patchHeader + "\n- 0 x 1\n",
+		"state":   patchHeader + "\n~ 0 0 1 4\n",
+		"added":   patchHeader + "\n+ 0 0 0\n",
+		"removed": patchHeader + "\n- 0 0 0\n",
+		"changed": patchHeader + "\n~ 0 0 0 1\n",
+	}
+
+	for name, src := range tests {
+		if _, err := ReadPatch(strings.NewReader(src)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// equalCells returns true if both lists hold the same cells, in the
+// same order. Nil and empty lists are equal.
+func equalCells(a, b sim.CellList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -11,6 +11,13 @@ import (
 func init() { runtime.LockOSThread() }
 
 func main() {
+	// Subcommands run without a window.
+	if len(os.Args) > 1 {
+		if cmd := findCommand(os.Args[1]); cmd != nil {
+			os.Exit(cmd.Run(os.Args[2:]))
+		}
+	}
+
 	config := ParseArgs()
 
 	// Initialize the window, opengl and all scene related things.
@@ -24,5 +31,10 @@ func main() {
 		scene.Draw()
 	}
 
+	scene.StoreConfig()
 	scene.Release()
+
+	if err := config.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
 }
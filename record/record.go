@@ -0,0 +1,197 @@
+// Package record captures simulation runs as animated GIF images or
+// as numbered sequences of PNG images.
+//
+// Frames are drawn by a software rasteriser, so recording does not
+// require an OpenGL context.
+package record
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"strings"
+	"time"
+
+	"wireworld/circuit"
+	"wireworld/render"
+	"wireworld/sim"
+)
+
+// MaxFramePixels defines the largest number of pixels in a single frame.
+const MaxFramePixels = 4096 * 4096
+
+// MaxGIFPixels defines the largest number of pixels of all frames of an
+// animated GIF together. GIF frames are kept in memory until the file is
+// written, at one byte per pixel, so this limits memory use to 256 MiB.
+const MaxGIFPixels = 256 << 20
+
+// minGIFDelay defines the shortest time between GIF frames, in units of
+// 10ms. Browsers play shorter delays at an arbitrary, slower speed.
+const minGIFDelay = 2
+
+// ErrFull is returned by Add when an animated GIF can not hold any more
+// frames without exceeding MaxGIFPixels. The frames recorded so far can
+// still be written with Close.
+var ErrFull = errors.New("record: frame limit reached")
+
+// Known output formats.
+const (
+	FormatGIF = "gif" // A single animated GIF.
+	FormatPNG = "png" // One PNG file per frame.
+)
+
+// Recorder captures frames of a fixed area of cells.
+type Recorder struct {
+	name   string // Output file, or file name pattern for PNG sequences.
+	format string
+	area   image.Rectangle
+	zoom   float64
+	pixels int // Number of pixels in a frame.
+	theme  render.Theme
+	delay  time.Duration
+	anim   gif.GIF
+	frames int
+}
+
+// New creates a recorder for the given output file. The format is
+// determined by the file name: names ending in .gif produce an animated
+// GIF, anything else is treated as a PNG sequence. A PNG sequence name
+// should contain a single integer verb, like "frame-%05d.png", which is
+// replaced with the frame number. If it has none, the frame number is
+// inserted before the file extension.
+//
+// Area defines the cells to record, each of which covers zoom by zoom
+// pixels. Below a zoom level of 1, multiple cells share a pixel. It is
+// an error if a frame would have more than MaxFramePixels pixels.
+// Delay defines the time between frames in an animated GIF. It is at
+// least 20ms.
+func New(name string, area image.Rectangle, zoom float64, colors circuit.ColorMap, delay time.Duration) (*Recorder, error) {
+	if area.Empty() || zoom <= 0 {
+		return nil, fmt.Errorf("record: empty area")
+	}
+
+	cam := render.CameraFor(area, zoom)
+	w, h := cam.Viewport[0], cam.Viewport[1]
+	if w*h > MaxFramePixels {
+		return nil, fmt.Errorf("record: frames of %dx%d pixels are too large", w, h)
+	}
+
+	r := Recorder{
+		name:   name,
+		format: FormatPNG,
+		area:   area,
+		zoom:   zoom,
+		pixels: w * h,
+		delay:  delay,
+		theme: render.Theme{
+			Background: colors[sim.CellEmpty],
+			Cells:      colors,
+		},
+	}
+
+	if strings.HasSuffix(strings.ToLower(name), ".gif") {
+		r.format = FormatGIF
+	} else if !strings.Contains(name, "%") {
+		ext := strings.LastIndex(name, ".")
+		if ext == -1 {
+			ext = len(name)
+		}
+		r.name = name[:ext] + "-%05d" + name[ext:]
+	}
+
+	return &r, nil
+}
+
+// Format returns the output format.
+func (r *Recorder) Format() string {
+	return r.format
+}
+
+// Frames returns the number of frames recorded so far.
+func (r *Recorder) Frames() int {
+	return r.frames
+}
+
+// Area returns the area of cells being recorded.
+func (r *Recorder) Area() image.Rectangle {
+	return r.area
+}
+
+// Add renders the given cells as a new frame. PNG frames are written
+// immediately. GIF frames are kept until Close is called, up to a total
+// of MaxGIFPixels. Beyond that, Add returns ErrFull.
+func (r *Recorder) Add(cells sim.CellList) error {
+	if r.format == FormatGIF && (r.frames+1)*r.pixels > MaxGIFPixels {
+		return ErrFull
+	}
+
+	img := render.RenderArea(cells, r.area, r.zoom, render.Options{Theme: &r.theme})
+
+	if r.format == FormatPNG {
+		r.frames++
+		return writePNG(fmt.Sprintf(r.name, r.frames-1), img)
+	}
+
+	r.frames++
+
+	// Every frame uses the same four colours, so there is no need
+	// for quantisation or dithering.
+	pal := make(color.Palette, len(r.theme.Cells))
+	for i, c := range r.theme.Cells {
+		pal[i] = c
+	}
+
+	frame := image.NewPaletted(img.Bounds(), pal)
+	for i := 0; i < len(img.Pix); i += 4 {
+		frame.Pix[i/4] = uint8(pal.Index(color.RGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]}))
+	}
+
+	delay := int(r.delay / (10 * time.Millisecond))
+	if delay < minGIFDelay {
+		delay = minGIFDelay
+	}
+
+	r.anim.Image = append(r.anim.Image, frame)
+	r.anim.Delay = append(r.anim.Delay, delay)
+	return nil
+}
+
+// Close finishes the recording. For animated GIFs this writes the
+// output file. Nothing is written if no frames have been recorded.
+func (r *Recorder) Close() error {
+	if r.format != FormatGIF || len(r.anim.Image) == 0 {
+		return nil
+	}
+
+	fd, err := os.Create(r.name)
+	if err != nil {
+		return err
+	}
+
+	if err = gif.EncodeAll(fd, &r.anim); err != nil {
+		fd.Close()
+		return err
+	}
+
+	r.anim = gif.GIF{}
+	return fd.Close()
+}
+
+// writePNG writes img to the given PNG file.
+func writePNG(name string, img image.Image) error {
+	fd, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err = png.Encode(fd, img); err != nil {
+		fd.Close()
+		return err
+	}
+
+	return fd.Close()
+}
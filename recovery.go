@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"wireworld/circuit"
+)
+
+// recoveryExt defines the file extension of recovery snapshots.
+const recoveryExt = ".json"
+
+// recoveryLockExt defines the file extension of session lock files.
+const recoveryLockExt = ".lock"
+
+// recoveryHeartbeat defines how often a session updates its lock file.
+const recoveryHeartbeat = 10 * time.Second
+
+// recoveryStale defines how long a lock file can go without an update
+// before its session is considered to have ended.
+const recoveryStale = time.Minute
+
+// recoveryDir returns the directory which holds recovery snapshots.
+// Returns an empty string if it can not be determined.
+func recoveryDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, AppName, "recovery")
+}
+
+// RecoverySnapshot defines the on-disk layout of a recovery snapshot.
+type RecoverySnapshot struct {
+	File    string          `json:"file"`    // Circuit file which was being edited.
+	Time    time.Time       `json:"time"`    // Time the snapshot was written.
+	Circuit json.RawMessage `json:"circuit"` // Circuit in the native file format.
+
+	name string // File the snapshot was read from.
+}
+
+// Load decodes the circuit stored in the snapshot.
+func (rs *RecoverySnapshot) Load() (*circuit.Circuit, error) {
+	c, err := circuit.Load(bytes.NewReader(rs.Circuit))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", rs.name, err)
+	}
+	return c, nil
+}
+
+// Recovery periodically writes the circuit and its editor data to
+// snapshot files. Snapshots written by a session are removed when it
+// ends normally, so any snapshots found on startup were left behind by
+// a session which did not.
+//
+// Multiple instances of the program share the snapshot directory. Each
+// session keeps a lock file there, which it updates regularly through
+// Heartbeat. Snapshots of other sessions whose lock file is recent and
+// whose process still exists belong to a running instance. They are
+// never offered for recovery, pruned or discarded.
+//
+// Snapshot names start with the time their session started, so sorting
+// them by name sorts them by age.
+type Recovery struct {
+	dir      string
+	keep     int           // Largest number of snapshots to keep.
+	interval time.Duration // Time between snapshots.
+	session  string        // Name prefix for this session's files.
+	seq      int           // Number of the next snapshot.
+	last     time.Time     // Time of the last snapshot.
+	beat     time.Time     // Time of the last lock file update.
+	protect  string        // Snapshot which must not be pruned.
+}
+
+// NewRecovery creates a new recovery writer for the given directory.
+func NewRecovery(dir string, interval time.Duration, keep int) *Recovery {
+	now := time.Now()
+	return &Recovery{
+		dir:      dir,
+		keep:     keep,
+		interval: interval,
+		session:  fmt.Sprintf("%s-%d", now.UTC().Format("20060102-150405.000"), os.Getpid()),
+		last:     now,
+	}
+}
+
+// Protect keeps the given snapshot from being pruned. This is used for
+// a snapshot the user has not yet decided to restore or discard.
+func (r *Recovery) Protect(rs *RecoverySnapshot) {
+	r.protect = ""
+	if rs != nil {
+		r.protect = rs.name
+	}
+}
+
+// Due returns true if it is time for a new snapshot.
+func (r *Recovery) Due() bool {
+	return r.interval > 0 && time.Since(r.last) >= r.interval
+}
+
+// Heartbeat marks this session as running, by updating its lock file.
+// It is meant to be called frequently, and only touches the file every
+// recoveryHeartbeat. Sessions without autosave need no lock file, as
+// they have no snapshots to protect.
+func (r *Recovery) Heartbeat() error {
+	if r.interval <= 0 || time.Since(r.beat) < recoveryHeartbeat {
+		return nil
+	}
+
+	if err := os.MkdirAll(r.dir, 0700); err != nil {
+		return err
+	}
+
+	r.beat = time.Now()
+	return ioutil.WriteFile(r.lockFile(r.session), nil, 0600)
+}
+
+// Save writes a snapshot of the given circuit. The oldest snapshots of
+// this and of ended sessions are removed until at most keep remain.
+// Callers are expected to skip the snapshot if nothing has changed.
+func (r *Recovery) Save(file string, c *circuit.Circuit) error {
+	r.last = time.Now()
+
+	if err := r.Heartbeat(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := circuit.Save(&buf, c); err != nil {
+		return err
+	}
+
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+
+	data, err := json.Marshal(&RecoverySnapshot{
+		File:    file,
+		Time:    r.last,
+		Circuit: buf.Bytes(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so a crash during the write
+	// does not leave a broken snapshot behind.
+	name := filepath.Join(r.dir, fmt.Sprintf("%s-%04d%s", r.session, r.seq, recoveryExt))
+	if err := ioutil.WriteFile(name+".tmp", data, 0600); err != nil {
+		return err
+	}
+
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return err
+	}
+
+	r.seq++
+	return r.prune()
+}
+
+// prune removes the oldest snapshots until at most keep remain.
+// Snapshots of other running sessions are left alone.
+func (r *Recovery) prune() error {
+	names, err := recoveryFiles(r.dir)
+	if err != nil {
+		return err
+	}
+
+	var set []string
+	for _, name := range names {
+		if session := snapshotSession(name); session == r.session || !r.live(session) {
+			set = append(set, name)
+		}
+	}
+
+	excess := len(set) - r.keep
+	for _, name := range set {
+		if excess <= 0 {
+			break
+		}
+
+		if name == r.protect {
+			continue
+		}
+
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+		excess--
+	}
+
+	return nil
+}
+
+// Discard removes the snapshots written by this session,
+// along with its lock file.
+func (r *Recovery) Discard() error {
+	if err := r.remove(true); err != nil {
+		return err
+	}
+
+	err := os.Remove(r.lockFile(r.session))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// DiscardOthers removes the snapshots and lock files left behind by
+// sessions which have ended. Those of running sessions are kept.
+func (r *Recovery) DiscardOthers() error {
+	r.protect = ""
+	if err := r.remove(false); err != nil {
+		return err
+	}
+
+	locks, err := filepath.Glob(filepath.Join(r.dir, "*"+recoveryLockExt))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range locks {
+		session := strings.TrimSuffix(filepath.Base(name), recoveryLockExt)
+		if session == r.session || r.live(session) {
+			continue
+		}
+
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// remove removes either this session's snapshots, or those of all
+// other sessions which have ended.
+func (r *Recovery) remove(own bool) error {
+	names, err := recoveryFiles(r.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		session := snapshotSession(name)
+		if (session == r.session) != own || (!own && r.live(session)) {
+			continue
+		}
+
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Latest returns the most recent snapshot left behind by a session
+// which has ended. Returns nil if there is none.
+func (r *Recovery) Latest() (*RecoverySnapshot, error) {
+	names, err := recoveryFiles(r.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(names) - 1; i >= 0; i-- {
+		if session := snapshotSession(names[i]); session == r.session || r.live(session) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(names[i])
+		if err != nil {
+			return nil, err
+		}
+
+		var rs RecoverySnapshot
+		if err := json.Unmarshal(data, &rs); err != nil {
+			return nil, fmt.Errorf("%s: %v", names[i], err)
+		}
+
+		rs.name = names[i]
+		return &rs, nil
+	}
+
+	return nil, nil
+}
+
+// live returns true if the given session is still running: its lock
+// file has been updated recently and its process still exists.
+func (r *Recovery) live(session string) bool {
+	fi, err := os.Stat(r.lockFile(session))
+	if err != nil || time.Since(fi.ModTime()) > recoveryStale {
+		return false
+	}
+
+	// Session names end with the process ID.
+	pid, err := strconv.Atoi(session[strings.LastIndex(session, "-")+1:])
+	return err == nil && processExists(pid)
+}
+
+// lockFile returns the name of the lock file for the given session.
+func (r *Recovery) lockFile(session string) string {
+	return filepath.Join(r.dir, session+recoveryLockExt)
+}
+
+// snapshotSession returns the session which wrote the given snapshot.
+// Snapshot names consist of the session name and a sequence number.
+func snapshotSession(name string) string {
+	base := strings.TrimSuffix(filepath.Base(name), recoveryExt)
+	if n := strings.LastIndex(base, "-"); n > -1 {
+		return base[:n]
+	}
+	return base
+}
+
+// processExists returns true if a process with the given ID exists.
+// On Windows, finding the process is enough. Elsewhere, FindProcess
+// always succeeds and signal 0 checks for existence without side
+// effects.
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	defer p.Release()
+
+	if runtime.GOOS == "windows" {
+		return true
+	}
+
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
+
+// recoveryFiles returns the names of all snapshots in the given
+// directory, oldest first. A missing directory holds no snapshots.
+func recoveryFiles(dir string) ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+recoveryExt))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
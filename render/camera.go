@@ -0,0 +1,88 @@
+// Package render draws cells and editor overlays into images, without
+// the need for OpenGL. It shares its camera model with the UI canvas,
+// so a rendered image matches what the canvas would show.
+package render
+
+import (
+	"image"
+	"math"
+)
+
+// Camera maps cell coordinates onto screen pixels.
+type Camera struct {
+	Origin   [2]float64 // Screen position of cell 0/0.
+	Zoom     float64    // Size of a single cell, in pixels.
+	Viewport [2]int     // Screen width and height, in pixels.
+}
+
+// CameraFor returns a camera whose viewport exactly covers the
+// given area of cells at the given zoom level.
+func CameraFor(area image.Rectangle, zoom float64) Camera {
+	return Camera{
+		Origin: [2]float64{
+			-float64(area.Min.X) * zoom,
+			-float64(area.Min.Y) * zoom,
+		},
+		Zoom: zoom,
+		Viewport: [2]int{
+			int(math.Ceil(float64(area.Dx()) * zoom)),
+			int(math.Ceil(float64(area.Dy()) * zoom)),
+		},
+	}
+}
+
+// VisibleCells returns the area of cells which is visible in the
+// viewport, in cell coordinates.
+func (c *Camera) VisibleCells() image.Rectangle {
+	x1 := math.Floor(-c.Origin[0] / c.Zoom)
+	y1 := math.Floor(-c.Origin[1] / c.Zoom)
+	x2 := math.Ceil((float64(c.Viewport[0]) - c.Origin[0]) / c.Zoom)
+	y2 := math.Ceil((float64(c.Viewport[1]) - c.Origin[1]) / c.Zoom)
+	return image.Rect(int(x1), int(y1), int(x2)+1, int(y2)+1)
+}
+
+// CellAt returns the cell coordinates at the given screen position.
+func (c *Camera) CellAt(x, y float64) (int32, int32) {
+	cx := math.Floor((x - c.Origin[0]) / c.Zoom)
+	cy := math.Floor((y - c.Origin[1]) / c.Zoom)
+	return int32(cx), int32(cy)
+}
+
+// ScreenPos returns the screen position of the top-left corner
+// of the given cell coordinates.
+func (c *Camera) ScreenPos(x, y float64) (float64, float64) {
+	return c.Origin[0] + x*c.Zoom, c.Origin[1] + y*c.Zoom
+}
+
+// Center returns the cell coordinates at the center of the viewport.
+func (c *Camera) Center() (float64, float64) {
+	x := (float64(c.Viewport[0])/2 - c.Origin[0]) / c.Zoom
+	y := (float64(c.Viewport[1])/2 - c.Origin[1]) / c.Zoom
+	return x, y
+}
+
+// SetCenter centers the viewport on the given cell coordinates.
+func (c *Camera) SetCenter(x, y float64) {
+	c.Origin[0] = float64(c.Viewport[0])/2 - x*c.Zoom
+	c.Origin[1] = float64(c.Viewport[1])/2 - y*c.Zoom
+}
+
+// ZoomAt sets the zoom factor to v, while keeping the cell at
+// screen position x/y in the same place.
+func (c *Camera) ZoomAt(v, x, y float64) {
+	wx := (x - c.Origin[0]) / c.Zoom
+	wy := (y - c.Origin[1]) / c.Zoom
+
+	c.Zoom = v
+	c.Origin[0] = x - wx*c.Zoom
+	c.Origin[1] = y - wy*c.Zoom
+}
+
+// FitZoom returns the zoom factor at which the given area of cells
+// fits the viewport. Margin is the fraction of the viewport which is
+// kept free on each side.
+func (c *Camera) FitZoom(r image.Rectangle, margin float64) float64 {
+	vw := float64(c.Viewport[0]) * (1 - margin*2)
+	vh := float64(c.Viewport[1]) * (1 - margin*2)
+	return math.Min(vw/float64(r.Dx()), vh/float64(r.Dy()))
+}
@@ -0,0 +1,211 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"wireworld/sim"
+)
+
+// GridZoomMin defines the lowest zoom level at which the grid is drawn.
+// Below this, the lines would be so close together that they obscure
+// the cells.
+const GridZoomMin = 4.0
+
+// ClipboardAlpha defines the opacity of clipboard contents.
+const ClipboardAlpha = 0.5
+
+// Options defines what is drawn by Render, besides the cells.
+type Options struct {
+	Camera        Camera
+	Theme         *Theme
+	Grid          bool            // Draw grid lines?
+	Selection     sim.CellList    // Selected cells.
+	SelectionRect image.Rectangle // Selection rectangle being drawn, in screen pixels.
+	Clipboard     sim.CellList    // Clipboard contents, relative to ClipboardAt.
+	ClipboardAt   image.Point     // Screen position of the clipboard's top-left cell.
+	Overlay       sim.CellList    // Cells drawn on top of everything else.
+	OverlayColors [4]color.NRGBA  // Colour for each overlay cell value.
+}
+
+// StatePriority returns the importance of a cell state, when multiple
+// cells have to be shown in a single pixel.
+func StatePriority(v uint8) int {
+	switch v {
+	case sim.CellHead:
+		return 3
+	case sim.CellTail:
+		return 2
+	case sim.CellWire:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Render draws the given cells, along with the overlays defined in opt,
+// as seen through opt.Camera. This mirrors what the UI canvas draws:
+// cells, grid, selection rectangle, selected cells, clipboard and
+// overlay, in that order.
+func Render(cells sim.CellList, opt *Options) *image.RGBA {
+	cam := &opt.Camera
+	t := opt.Theme
+	img := image.NewRGBA(image.Rect(0, 0, cam.Viewport[0], cam.Viewport[1]))
+
+	fillRect(img, img.Bounds(), t.Background, 1)
+	drawCells(img, cam, cells, cam.Origin[0], cam.Origin[1], t.Cells, 1)
+
+	if opt.Grid && cam.Zoom >= GridZoomMin {
+		drawGrid(img, cam, t.Grid)
+	}
+
+	if !opt.SelectionRect.Empty() {
+		fillRect(img, opt.SelectionRect.Canon(), t.SelectionRect, 1)
+	}
+
+	if opt.Selection.Len() > 0 {
+		var pal [4]color.NRGBA
+		for i := range pal {
+			pal[i] = t.Selection
+		}
+		drawCells(img, cam, opt.Selection, cam.Origin[0], cam.Origin[1], pal, 1)
+	}
+
+	if opt.Clipboard.Len() > 0 {
+		drawCells(img, cam, opt.Clipboard, float64(opt.ClipboardAt.X),
+			float64(opt.ClipboardAt.Y), t.Cells, ClipboardAlpha)
+	}
+
+	if opt.Overlay.Len() > 0 {
+		drawCells(img, cam, opt.Overlay, cam.Origin[0], cam.Origin[1], opt.OverlayColors, 1)
+	}
+
+	return img
+}
+
+// RenderArea draws the given area of cells at the given zoom level.
+// Refer to Render for details. The camera in opt is replaced.
+func RenderArea(cells sim.CellList, area image.Rectangle, zoom float64, opt Options) *image.RGBA {
+	opt.Camera = CameraFor(area, zoom)
+	return Render(cells, &opt)
+}
+
+// drawCells draws all non-empty cells in the viewport, where cell 0/0
+// is located at screen position ox/oy. Cells are blended with the given
+// opacity. When multiple cells share a pixel, the one with the highest
+// state priority is shown.
+func drawCells(img *image.RGBA, cam *Camera, cells sim.CellList, ox, oy float64, pal [4]color.NRGBA, alpha float64) {
+	b := img.Bounds()
+	z := cam.Zoom
+
+	// Tracks the state drawn at each pixel, when cells are smaller
+	// than a pixel.
+	var states []uint8
+	if z < 1 {
+		states = make([]uint8, b.Dx()*b.Dy())
+	}
+
+	for i := 0; i < len(cells)-2; i += 3 {
+		v := uint8(cells[i+2])
+		if v == sim.CellEmpty {
+			continue
+		}
+
+		x0 := ox + float64(cells[i])*z
+		y0 := oy + float64(cells[i+1])*z
+		r := image.Rect(
+			int(math.Floor(x0)), int(math.Floor(y0)),
+			int(math.Floor(x0+z)), int(math.Floor(y0+z)))
+
+		// Make sure every cell covers at least a single pixel.
+		if r.Dx() == 0 {
+			r.Max.X++
+		}
+		if r.Dy() == 0 {
+			r.Max.Y++
+		}
+
+		r = r.Intersect(b)
+		if r.Empty() {
+			continue
+		}
+
+		if states != nil {
+			n := r.Min.Y*b.Dx() + r.Min.X
+			if StatePriority(v) <= StatePriority(states[n]) {
+				continue
+			}
+			states[n] = v
+		}
+
+		fillRect(img, r, pal[v&3], alpha)
+	}
+}
+
+// drawGrid draws a line of a single pixel along each cell boundary.
+func drawGrid(img *image.RGBA, cam *Camera, c color.NRGBA) {
+	b := img.Bounds()
+	z := cam.Zoom
+
+	for x := math.Mod(cam.Origin[0], z); x < float64(b.Max.X); x += z {
+		if px := int(math.Floor(x)); px >= 0 {
+			fillRect(img, image.Rect(px, b.Min.Y, px+1, b.Max.Y), c, 1)
+		}
+	}
+
+	for y := math.Mod(cam.Origin[1], z); y < float64(b.Max.Y); y += z {
+		if py := int(math.Floor(y)); py >= 0 {
+			fillRect(img, image.Rect(b.Min.X, py, b.Max.X, py+1), c, 1)
+		}
+	}
+}
+
+// fillRect blends colour c over the given area of img. The colour is
+// treated as non-premultiplied, its alpha scaled by the given opacity.
+// The image is assumed to be opaque.
+func fillRect(img *image.RGBA, r image.Rectangle, c color.NRGBA, alpha float64) {
+	r = r.Intersect(img.Bounds())
+	a := float64(c.A) / 255 * alpha
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		pix := img.Pix[img.PixOffset(r.Min.X, y):img.PixOffset(r.Max.X, y)]
+		for i := 0; i < len(pix); i += 4 {
+			pix[i+0] = blend(pix[i+0], c.R, a)
+			pix[i+1] = blend(pix[i+1], c.G, a)
+			pix[i+2] = blend(pix[i+2], c.B, a)
+			pix[i+3] = 0xff
+		}
+	}
+}
+
+// blend mixes channel value src over dst with the given opacity.
+func blend(dst, src uint8, a float64) uint8 {
+	return uint8(math.Round(float64(src)*a + float64(dst)*(1-a)))
+}
+
+// Diff returns the number of pixels which differ between a and b.
+// Images of different sizes differ in every pixel of the larger one.
+func Diff(a, b image.Image) int {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Size() != bb.Size() {
+		n := ab.Dx() * ab.Dy()
+		if m := bb.Dx() * bb.Dy(); m > n {
+			n = m
+		}
+		return n
+	}
+
+	var n int
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			r1, g1, b1, a1 := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r2, g2, b2, a2 := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				n++
+			}
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,240 @@
+package render
+
+import (
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"wireworld/sim"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden images in testdata.")
+
+// testCells holds a small circuit with every cell state.
+var testCells = sim.CellList{
+	0, 0, sim.CellWire,
+	1, 0, sim.CellWire,
+	2, 0, sim.CellHead,
+	3, 0, sim.CellTail,
+	4, 0, sim.CellWire,
+	4, 1, sim.CellWire,
+	4, 2, sim.CellWire,
+	3, 3, sim.CellWire,
+	5, 3, sim.CellWire,
+	2, 4, sim.CellHead,
+	6, 4, sim.CellTail,
+	-2, -1, sim.CellWire,
+}
+
+func TestRenderGolden(t *testing.T) {
+	theme := FindTheme("light")
+
+	tests := []struct {
+		name string
+		opt  Options
+	}{
+		{
+			name: "cells-zoom4",
+			opt: Options{
+				Camera: Camera{Origin: [2]float64{10.5, 6.25}, Zoom: 4, Viewport: [2]int{48, 32}},
+			},
+		},
+		{
+			// Multiple cells share each pixel, so the state priority
+			// decides which one is shown.
+			name: "cells-zoom0.25",
+			opt: Options{
+				Camera: Camera{Origin: [2]float64{1, 1}, Zoom: 0.25, Viewport: [2]int{4, 4}},
+			},
+		},
+		{
+			name: "grid",
+			opt: Options{
+				Camera: Camera{Origin: [2]float64{20, 12}, Zoom: 8, Viewport: [2]int{80, 56}},
+				Grid:   true,
+			},
+		},
+		{
+			name: "selection",
+			opt: Options{
+				Camera:        Camera{Origin: [2]float64{20, 12}, Zoom: 8, Viewport: [2]int{80, 56}},
+				Selection:     sim.CellList{0, 0, sim.CellWire, 1, 0, sim.CellWire, 2, 0, sim.CellHead},
+				SelectionRect: image.Rect(30, 40, 10, 8),
+			},
+		},
+		{
+			name: "clipboard",
+			opt: Options{
+				Camera:      Camera{Origin: [2]float64{20, 12}, Zoom: 8, Viewport: [2]int{80, 56}},
+				Clipboard:   sim.CellList{0, 0, sim.CellHead, 1, 0, sim.CellTail, 2, 0, sim.CellWire, 2, 1, sim.CellWire},
+				ClipboardAt: image.Pt(44, 36),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opt.Theme = theme
+			img := Render(testCells, &tt.opt)
+			compareGolden(t, tt.name, img)
+		})
+	}
+}
+
+func TestRenderStatePriority(t *testing.T) {
+	theme := FindTheme("light")
+
+	// All four cells land on pixel 0/0.
+	cells := sim.CellList{
+		0, 0, sim.CellWire,
+		1, 0, sim.CellTail,
+		2, 0, sim.CellHead,
+		3, 0, sim.CellWire,
+	}
+
+	img := Render(cells, &Options{
+		Camera: Camera{Zoom: 0.25, Viewport: [2]int{2, 2}},
+		Theme:  theme,
+	})
+
+	want := theme.Cells[sim.CellHead]
+	if got := img.RGBAAt(0, 0); got.R != want.R || got.G != want.G || got.B != want.B {
+		t.Fatalf("pixel 0,0: got %v, want %v", got, want)
+	}
+}
+
+func TestRenderClipboardAlpha(t *testing.T) {
+	theme := FindTheme("light")
+	img := Render(nil, &Options{
+		Camera:    Camera{Zoom: 1, Viewport: [2]int{1, 1}},
+		Theme:     theme,
+		Clipboard: sim.CellList{0, 0, sim.CellHead},
+	})
+
+	bg, fg := theme.Background, theme.Cells[sim.CellHead]
+	want := blend(bg.R, fg.R, float64(fg.A)/255*ClipboardAlpha)
+	if got := img.RGBAAt(0, 0).R; got != want {
+		t.Fatalf("red channel: got %d, want %d", got, want)
+	}
+}
+
+// TestCameraCanvas checks the camera against values computed by the
+// UI canvas, before it shared its camera with this package.
+func TestCameraCanvas(t *testing.T) {
+	tests := []struct {
+		cam     Camera
+		visible image.Rectangle
+		cellAt  [][4]float64 // Screen x, y and the expected cell x, y.
+	}{
+		{
+			Camera{Origin: [2]float64{0, 0}, Zoom: 16, Viewport: [2]int{1280, 800}},
+			image.Rect(0, 0, 81, 51),
+			[][4]float64{{0, 0, 0, 0}, {100.5, 50.25, 6, 3}, {-3, 7, -1, 0}, {639.9, 479.9, 39, 29}},
+		},
+		{
+			Camera{Origin: [2]float64{640, 400}, Zoom: 16, Viewport: [2]int{1280, 800}},
+			image.Rect(-40, -25, 41, 26),
+			[][4]float64{{0, 0, -40, -25}, {100.5, 50.25, -34, -22}, {-3, 7, -41, -25}, {639.9, 479.9, -1, 4}},
+		},
+		{
+			Camera{Origin: [2]float64{-1234.5, 987.25}, Zoom: 3.7, Viewport: [2]int{1024, 768}},
+			image.Rect(333, -267, 612, -58),
+			[][4]float64{{0, 0, 333, -267}, {100.5, 50.25, 360, -254}, {-3, 7, 332, -265}, {639.9, 479.9, 506, -138}},
+		},
+		{
+			Camera{Origin: [2]float64{333.3, -77.7}, Zoom: 0.125, Viewport: [2]int{800, 600}},
+			image.Rect(-2667, 621, 3735, 5423),
+			[][4]float64{{0, 0, -2667, 621}, {100.5, 50.25, -1863, 1023}, {-3, 7, -2691, 677}, {639.9, 479.9, 2452, 4460}},
+		},
+		{
+			Camera{Origin: [2]float64{-5, -5}, Zoom: 0.03125, Viewport: [2]int{640, 480}},
+			image.Rect(160, 160, 20641, 15521),
+			[][4]float64{{0, 0, 160, 160}, {100.5, 50.25, 3376, 1768}, {-3, 7, 64, 384}, {639.9, 479.9, 20636, 15516}},
+		},
+	}
+
+	for i, tt := range tests {
+		if got := tt.cam.VisibleCells(); got != tt.visible {
+			t.Errorf("camera %d: VisibleCells: got %v, want %v", i, got, tt.visible)
+		}
+
+		for _, v := range tt.cellAt {
+			x, y := tt.cam.CellAt(v[0], v[1])
+			if x != int32(v[2]) || y != int32(v[3]) {
+				t.Errorf("camera %d: CellAt(%g, %g): got %d,%d, want %g,%g", i, v[0], v[1], x, y, v[2], v[3])
+			}
+		}
+	}
+}
+
+func TestCameraFor(t *testing.T) {
+	area := image.Rect(-3, 2, 7, 6)
+	cam := CameraFor(area, 2.5)
+
+	if cam.Viewport != [2]int{25, 10} {
+		t.Fatalf("viewport: got %v, want [25 10]", cam.Viewport)
+	}
+
+	if x, y := cam.CellAt(0, 0); x != -3 || y != 2 {
+		t.Fatalf("CellAt(0, 0): got %d,%d, want -3,2", x, y)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	b := image.NewRGBA(image.Rect(0, 0, 4, 4))
+
+	if n := Diff(a, b); n != 0 {
+		t.Fatalf("identical images: got %d, want 0", n)
+	}
+
+	b.Pix[0] = 1
+	b.Pix[len(b.Pix)-1] = 1
+	if n := Diff(a, b); n != 2 {
+		t.Fatalf("changed images: got %d, want 2", n)
+	}
+
+	if n := Diff(a, image.NewRGBA(image.Rect(0, 0, 5, 4))); n != 20 {
+		t.Fatalf("resized images: got %d, want 20", n)
+	}
+}
+
+// compareGolden compares img with testdata/<name>.png. With -update,
+// the golden image is rewritten instead.
+func compareGolden(t *testing.T, name string, img image.Image) {
+	t.Helper()
+	file := filepath.Join("testdata", name+".png")
+
+	if *update {
+		fd, err := os.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer fd.Close()
+
+		if err := png.Encode(fd, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	fd, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer fd.Close()
+
+	want, err := png.Decode(fd)
+	if err != nil {
+		t.Fatalf("%s: %v", file, err)
+	}
+
+	if n := Diff(img, want); n > 0 {
+		t.Fatalf("%s: %d pixels differ", file, n)
+	}
+}
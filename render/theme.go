@@ -0,0 +1,104 @@
+package render
+
+import "image/color"
+
+// Theme defines the colours used to draw the UI. Colours are not
+// premultiplied by their alpha.
+type Theme struct {
+	Name          string
+	Background    color.NRGBA    // Canvas background.
+	Cells         [4]color.NRGBA // Colour for each cell state.
+	Grid          color.NRGBA    // Grid lines.
+	Selection     color.NRGBA    // Selected cells.
+	SelectionRect color.NRGBA    // Selection rectangle.
+	Panel         color.NRGBA    // Panel backgrounds.
+	PanelText     color.NRGBA    // Panel text.
+}
+
+// defaultThemes defines the built-in themes. The first entry is the default.
+var defaultThemes = []Theme{
+	{
+		Name:       "light",
+		Background: color.NRGBA{0xe6, 0xe6, 0xe6, 0xff},
+		Cells: [4]color.NRGBA{
+			{0xe6, 0xe6, 0xe6, 0xff},
+			{0xff, 0x98, 0x00, 0xff},
+			{0x00, 0x98, 0xff, 0xff},
+			{0x98, 0x00, 0xff, 0xff},
+		},
+		Grid:          color.NRGBA{0xbf, 0xbf, 0xbf, 0xff},
+		Selection:     color.NRGBA{0xff, 0x00, 0x98, 0x66},
+		SelectionRect: color.NRGBA{0x00, 0x98, 0xff, 0x66},
+		Panel:         color.NRGBA{0xb3, 0xb3, 0xb3, 0xff},
+		PanelText:     color.NRGBA{0x00, 0x00, 0x00, 0xff},
+	},
+	{
+		Name:       "dark",
+		Background: color.NRGBA{0x1e, 0x1e, 0x1e, 0xff},
+		Cells: [4]color.NRGBA{
+			{0x1e, 0x1e, 0x1e, 0xff},
+			{0xc8, 0x78, 0x00, 0xff},
+			{0x40, 0xb0, 0xff, 0xff},
+			{0xb0, 0x60, 0xff, 0xff},
+		},
+		Grid:          color.NRGBA{0x33, 0x33, 0x33, 0xff},
+		Selection:     color.NRGBA{0xff, 0x4f, 0xb0, 0x66},
+		SelectionRect: color.NRGBA{0x40, 0xb0, 0xff, 0x66},
+		Panel:         color.NRGBA{0x2b, 0x2b, 0x2b, 0xff},
+		PanelText:     color.NRGBA{0xdd, 0xdd, 0xdd, 0xff},
+	},
+	{
+		Name:       "high-contrast",
+		Background: color.NRGBA{0x00, 0x00, 0x00, 0xff},
+		Cells: [4]color.NRGBA{
+			{0x00, 0x00, 0x00, 0xff},
+			{0xff, 0xff, 0x00, 0xff},
+			{0xff, 0xff, 0xff, 0xff},
+			{0xff, 0x00, 0x00, 0xff},
+		},
+		Grid:          color.NRGBA{0x40, 0x40, 0x40, 0xff},
+		Selection:     color.NRGBA{0x00, 0xff, 0x00, 0x80},
+		SelectionRect: color.NRGBA{0x00, 0xff, 0xff, 0x80},
+		Panel:         color.NRGBA{0x00, 0x00, 0x00, 0xff},
+		PanelText:     color.NRGBA{0xff, 0xff, 0xff, 0xff},
+	},
+	{
+		// Based on the Okabe-Ito palette, which remains distinguishable
+		// for the common forms of colour vision deficiency.
+		Name:       "colour-blind",
+		Background: color.NRGBA{0xf0, 0xf0, 0xf0, 0xff},
+		Cells: [4]color.NRGBA{
+			{0xf0, 0xf0, 0xf0, 0xff},
+			{0xe6, 0x9f, 0x00, 0xff},
+			{0x00, 0x72, 0xb2, 0xff},
+			{0xcc, 0x79, 0xa7, 0xff},
+		},
+		Grid:          color.NRGBA{0xc8, 0xc8, 0xc8, 0xff},
+		Selection:     color.NRGBA{0x00, 0x9e, 0x73, 0x66},
+		SelectionRect: color.NRGBA{0x56, 0xb4, 0xe9, 0x66},
+		Panel:         color.NRGBA{0xb3, 0xb3, 0xb3, 0xff},
+		PanelText:     color.NRGBA{0x00, 0x00, 0x00, 0xff},
+	},
+}
+
+// DefaultThemes returns copies of the built-in themes.
+// The first entry is the default.
+func DefaultThemes() []*Theme {
+	out := make([]*Theme, len(defaultThemes))
+	for i := range defaultThemes {
+		t := defaultThemes[i]
+		out[i] = &t
+	}
+	return out
+}
+
+// FindTheme returns a copy of the built-in theme with the given name,
+// or nil if there is none.
+func FindTheme(name string) *Theme {
+	for _, t := range DefaultThemes() {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
@@ -21,10 +21,14 @@ type TexturedQuadMesh struct {
 	vboPos  uint32
 	vboTex  uint32
 	texture uint32
+	filter  int32 // Texture minification filter.
 }
 
-func newTexturedQuadMesh() Mesh {
+// newTexturedQuadMesh creates a new textured quad. The filter determines
+// how the texture is sampled when it is drawn at less than its full size.
+func newTexturedQuadMesh(filter int32) Mesh {
 	var m TexturedQuadMesh
+	m.filter = filter
 
 	gl.GenVertexArrays(1, &m.vao)
 	gl.BindVertexArray(m.vao)
@@ -80,7 +84,7 @@ func (m *TexturedQuadMesh) CommitTexture(img *image.RGBA, w, h int) {
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, m.filter)
 	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(tb.Dx()), int32(tb.Dy()),
 		0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
 
@@ -180,6 +184,12 @@ type CellMesh struct {
 	size int32
 }
 
+// NewCellMesh creates a new cell mesh which is not managed by the
+// resource loader. The caller is responsible for releasing it.
+func NewCellMesh() Mesh {
+	return newCellMesh()
+}
+
 func newCellMesh() Mesh {
 	var m CellMesh
 	gl.GenVertexArrays(1, &m.vao)
@@ -215,3 +225,58 @@ func (m *CellMesh) Commitiv(set []int32, usage uint32) {
 		gl.BufferData(gl.ARRAY_BUFFER, 1, nil, usage)
 	}
 }
+
+// TextMesh defines a set of textured, coloured quads. Each quad is
+// made up of two triangles. Each vertex is defined by 8 floats:
+// the X/Y position, the U/V texture coordinates and an RGBA colour.
+type TextMesh struct {
+	vao  uint32
+	vbo  uint32
+	size int32
+}
+
+// NewTextMesh creates a new text mesh which is not managed by the
+// resource loader. The caller is responsible for releasing it.
+func NewTextMesh() Mesh {
+	return newTextMesh()
+}
+
+func newTextMesh() Mesh {
+	var m TextMesh
+	gl.GenVertexArrays(1, &m.vao)
+	gl.BindVertexArray(m.vao)
+	gl.GenBuffers(1, &m.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, m.vbo)
+	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, 8*4, gl.PtrOffset(0))
+	gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 8*4, gl.PtrOffset(2*4))
+	gl.VertexAttribPointer(2, 4, gl.FLOAT, false, 8*4, gl.PtrOffset(4*4))
+	gl.EnableVertexAttribArray(0)
+	gl.EnableVertexAttribArray(1)
+	gl.EnableVertexAttribArray(2)
+	return &m
+}
+
+// Release clears mesh resources.
+func (m *TextMesh) Release() {
+	gl.DeleteVertexArrays(1, &m.vao)
+	gl.DeleteBuffers(1, &m.vbo)
+}
+
+// Draw renders the mesh.
+func (m *TextMesh) Draw() {
+	gl.BindVertexArray(m.vao)
+	gl.DrawArrays(gl.TRIANGLES, 0, m.size)
+}
+
+func (m *TextMesh) Commitiv([]int32, uint32) {}
+func (m *TextMesh) Commitfv(set []float32, usage uint32) {
+	m.size = int32(len(set) / 8)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, m.vbo)
+
+	if len(set) > 0 {
+		gl.BufferData(gl.ARRAY_BUFFER, len(set)*4, gl.Ptr(set), usage)
+	} else {
+		gl.BufferData(gl.ARRAY_BUFFER, 1, nil, usage)
+	}
+}
@@ -3,6 +3,8 @@ package resources
 import (
 	"strings"
 	"sync"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
 )
 
 // meshes provides global access to mesh resources.
@@ -42,12 +44,16 @@ func (ml *meshLoader) Release() {
 func (ml *meshLoader) Load() error {
 	ml.m.Lock()
 
-	ml.loadMesh("Panel", newTexturedQuadMesh())
+	ml.loadMesh("Panel", newQuadMesh())
 	ml.loadMesh("CellSelectorRect", newQuadMesh())
 	ml.loadMesh("CellSelectorCells", newCellMesh())
-	ml.loadMesh("CellRenderer", newCellMesh())
 	ml.loadMesh("Clipboard", newCellMesh())
 	ml.loadMesh("Grid", newGridMesh())
+	ml.loadMesh("Annotations", newTextMesh())
+	ml.loadMesh("CellTexture", newTexturedQuadMesh(gl.NEAREST))
+	ml.loadMesh("Minimap", newTexturedQuadMesh(gl.LINEAR))
+	ml.loadMesh("Heatmap", newCellMesh())
+	ml.loadMesh("Diff", newCellMesh())
 
 	ml.m.Unlock()
 	return nil
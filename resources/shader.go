@@ -99,6 +99,14 @@ func (s *Shader) Set4f(name string, a, b, c, d float32) error {
 	})
 }
 
+// Set4fv sets the given vec4 array uniform. The length of v
+// is expected to be a multiple of 4.
+func (s *Shader) Set4fv(name string, v []float32) error {
+	return s.set(name, func(loc int32) {
+		gl.Uniform4fv(loc, int32(len(v)/4), &v[0])
+	})
+}
+
 // SetMat16 sets the given uniform.
 func (s *Shader) SetMat16(name string, m []float32) error {
 	return s.set(name, func(loc int32) {
@@ -47,6 +47,14 @@ func (sl *shaderLoader) Load() error {
 		return err
 	}
 
+	if err := sl.loadShader("CellTexture", cellTextureSources); err != nil {
+		return err
+	}
+
+	if err := sl.loadShader("Heatmap", heatmapSources); err != nil {
+		return err
+	}
+
 	if err := sl.loadShader("CellSelectorRect", cellSelectorRectSources); err != nil {
 		return err
 	}
@@ -63,6 +71,14 @@ func (sl *shaderLoader) Load() error {
 		return err
 	}
 
+	if err := sl.loadShader("Text", textSources); err != nil {
+		return err
+	}
+
+	if err := sl.loadShader("Texture", textureSources); err != nil {
+		return err
+	}
+
 	return nil
 }
 
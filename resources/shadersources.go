@@ -3,6 +3,30 @@ package resources
 var panelSources = [3]string{
 	`#version 330 core
 
+	layout (location = 0) in vec2 vPos;
+	
+	uniform mat4 mvp;
+	
+	void main()
+	{
+		gl_Position = mvp * vec4(vPos, 0.0, 1.0);
+	}`,
+	``,
+	`#version 330 core
+
+	uniform vec4 color;
+	
+	out vec4 fragColor;
+	
+	void main()
+	{
+		fragColor = color;
+	}`,
+}
+
+var textureSources = [3]string{
+	`#version 330 core
+
 	layout (location = 0) in vec2 vPos;
 	layout (location = 1) in vec2 vUV;
 	
@@ -28,6 +52,38 @@ var panelSources = [3]string{
 	}`,
 }
 
+var textSources = [3]string{
+	`#version 330 core
+
+	layout (location = 0) in vec2 vPos;
+	layout (location = 1) in vec2 vUV;
+	layout (location = 2) in vec4 vColor;
+	
+	uniform mat4 mvp;
+	out vec2 fUV;
+	out vec4 fColor;
+	
+	void main()
+	{
+		gl_Position = mvp * vec4(vPos, 0.0, 1.0);
+		fUV = vUV;
+		fColor = vColor;
+	}`,
+	``,
+	`#version 330 core
+
+	uniform sampler2D img;
+	
+	in  vec2 fUV;
+	in  vec4 fColor;
+	out vec4 fragColor;
+	
+	void main()
+	{
+		fragColor = vec4(fColor.rgb, fColor.a * texture(img, fUV).a);
+	}`,
+}
+
 var cellSelectorCellsSources = [3]string{
 	`#version 330 core
 	
@@ -73,11 +129,13 @@ var cellSelectorCellsSources = [3]string{
 	}`,
 	`#version 330 core
 
+	uniform vec4 color;
+	
 	out vec4 fragColor;
 	
 	void main()
 	{
-		fragColor = vec4(1.0, 0.0, 0.596, 0.4);
+		fragColor = color;
 	}`,
 }
 
@@ -95,11 +153,13 @@ var cellSelectorRectSources = [3]string{
 	``,
 	`#version 330 core
 	
+	uniform vec4 color;
+	
 	out vec4 fragColor;
 	
 	void main()
 	{
-		fragColor = vec4(0.0, 0.596, 1.0, 0.4);
+		fragColor = color;
 	}`,
 }
 
@@ -117,11 +177,13 @@ var gridSources = [3]string{
 	``,
 	`#version 330 core
 	
+	uniform vec4 color;
+	
 	out vec4 fragColor;
 	
 	void main()
 	{
-		fragColor = vec4(0.75, 0.75, 0.75, 1.0);
+		fragColor = color;
 	}`,
 }
 
@@ -179,12 +241,7 @@ var cellRendererSources = [3]string{
 	
 	uniform float alpha = 1.0;
 	
-	const vec4 palette[4] = vec4[](
-		vec4(0.9,   0.9,   0.9, 1.0),
-		vec4(1.0,   0.596, 0.0, 1.0),
-		vec4(0.0,   0.596, 1.0, 1.0),
-		vec4(0.596, 0.0,   1.0, 1.0)
-	);
+	uniform vec4 palette[4];
 	
 	flat in int fsColor;
 	out vec4 fragColor;
@@ -194,3 +251,69 @@ var cellRendererSources = [3]string{
 		fragColor = palette[fsColor] * vec4(1, 1, 1, alpha);
 	}`,
 }
+
+// heatmapSources expands cells the same way as the cell renderer.
+// The cell state is replaced by an activity count, which is mapped
+// onto a blue-yellow-red gradient.
+var heatmapSources = [3]string{
+	cellRendererSources[0],
+	cellRendererSources[1],
+	`#version 330 core
+	
+	uniform float alpha = 1.0;
+	uniform float maxHeat = 1.0;
+	
+	flat in int fsColor;
+	out vec4 fragColor;
+	
+	void main()
+	{
+		float t = clamp(float(fsColor) / maxHeat, 0.0, 1.0);
+		vec3 c;
+
+		if (t < 0.5) {
+			c = mix(vec3(0.0, 0.2, 1.0), vec3(1.0, 1.0, 0.0), t * 2.0);
+		} else {
+			c = mix(vec3(1.0, 1.0, 0.0), vec3(1.0, 0.0, 0.0), t * 2.0 - 1.0);
+		}
+
+		fragColor = vec4(c, alpha);
+	}`,
+}
+
+var cellTextureSources = [3]string{
+	`#version 330 core
+
+	layout (location = 0) in vec2 vPos;
+	layout (location = 1) in vec2 vUV;
+	
+	uniform mat4 mvp;
+	out vec2 fUV;
+	
+	void main()
+	{
+		gl_Position = mvp * vec4(vPos, 0.0, 1.0);
+		fUV = vUV;
+	}`,
+	``,
+	`#version 330 core
+	
+	uniform sampler2D img;
+	uniform float alpha = 1.0;
+	
+	uniform vec4 palette[4];
+	
+	in  vec2 fUV;
+	out vec4 fragColor;
+	
+	void main()
+	{
+		// The red channel holds the cell state.
+		int state = int(texture(img, fUV).r * 255.0 + 0.5);
+		if (state == 0) {
+			discard;
+		}
+	
+		fragColor = palette[state] * vec4(1, 1, 1, alpha);
+	}`,
+}
@@ -1,6 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wireworld/circuit"
+	"wireworld/diff"
+	"wireworld/record"
 	"wireworld/resources"
 	"wireworld/sim"
 	"wireworld/ui"
@@ -10,6 +18,27 @@ import (
 	"github.com/go-gl/glfw/v3.2/glfw"
 )
 
+// defaultFile defines the file circuits are saved to if none
+// was specified on the commandline.
+const defaultFile = "circuit.json"
+
+// svgCellSize defines the size of a cell in exported SVG diagrams.
+const svgCellSize = 10
+
+// Minimap dimensions, in pixels.
+const (
+	minimapWidth  = 240
+	minimapHeight = 160
+)
+
+// Known drawing modes. These determine how the current tool is
+// applied to the cells under the mouse cursor.
+const (
+	drawModeDraw    = iota // Set individual cells.
+	drawModeFill           // Fill a region of cells with the same state.
+	drawModeFillNet        // Fill an entire net of connected cells.
+)
+
 // Scene defines a window with loads of drawing and simulation
 // manipulation functionality.
 type Scene struct {
@@ -17,8 +46,29 @@ type Scene struct {
 	window      *ui.Window
 	canvas      *ui.Clipboard
 	panel       *ui.InfoPanel
+	annotations *ui.Annotations
+	minimap     *ui.Minimap
+	heatmap     *ui.Heatmap
+	diffView    *ui.DiffView
+	diffBase    sim.CellList      // Cells the simulation is compared with.
+	diffRev     [2]uint64         // Edits and generation of the last comparison.
+	diffStale   bool              // Comparison must be redone, regardless of diffRev.
+	diffSignals bool              // Compare signals, instead of just the wiring.
+	meta        map[string]string // Metadata of the current circuit.
+	statusBar   *ui.StatusBar
+	keymap      *ui.Keymap
+	config      *Config
+	recorder    *record.Recorder
+	recordGen   uint64 // Generation of the last recorded frame.
+	recovery    *Recovery
+	pending     *RecoverySnapshot // Snapshot from a crashed session, awaiting a decision.
+	savedRev    [4]uint64         // Revision of the last recovery snapshot.
 	history     sim.History
+	bookmarks   map[int]circuit.Bookmark
+	bookmarkRev uint64 // Incremented whenever a bookmark is stored.
+	file        string
 	currentTool int
+	drawMode    int
 	lmbPressed  bool
 	infoVisible bool
 }
@@ -34,17 +84,60 @@ func CreateScene(c *Config) (*Scene, error) {
 		return nil, err
 	}
 
+	if c.Positioned && !c.Fullscreen {
+		s.window.SetPos(c.X, c.Y)
+	}
+
 	err = resources.Load()
 	if err != nil {
 		s.Release()
 		return nil, err
 	}
 
+	// A missing theme file is not an error. It is entirely optional.
+	// Bad theme settings are only fatal when given on the commandline.
+	if len(c.ThemeFile) > 0 {
+		if err := ui.LoadThemes(c.ThemeFile); err != nil && !os.IsNotExist(err) {
+			if c.fromFlag("themes") {
+				s.Release()
+				return nil, err
+			}
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	if !ui.SetTheme(c.Theme) {
+		if c.fromFlag("theme") {
+			s.Release()
+			return nil, fmt.Errorf("unknown theme %q", c.Theme)
+		}
+
+		fmt.Fprintf(os.Stderr, "unknown theme %q; using %q\n", c.Theme, c.defaults.Theme)
+		ui.SetTheme(c.defaults.Theme)
+	}
+
+	s.keymap, err = loadKeymap(c.KeymapFile)
+	if err != nil {
+		s.Release()
+		return nil, err
+	}
+
 	s.panel = ui.NewInfoPanel()
 	s.canvas = ui.NewClipboard()
+	s.annotations = ui.NewAnnotations()
+	s.minimap = ui.NewMinimap()
+	s.heatmap = ui.NewHeatmap()
+	s.diffView = ui.NewDiffView()
+	s.statusBar = ui.NewStatusBar()
+	s.bookmarks = make(map[int]circuit.Bookmark)
+	s.config = c
+	s.file = c.File
 	s.currentTool = sim.CellWire
+	s.drawMode = drawModeDraw
 	s.lmbPressed = false
-	s.infoVisible = true
+	s.infoVisible = c.InfoVisible
+	s.canvas.SetGridVisible(c.GridVisible)
+	sim.SetStepInterval(c.StepInterval)
 
 	s.window.SetTitle(Version())
 	s.window.SetKeyCallback(s.keyCallback)
@@ -53,18 +146,84 @@ func CreateScene(c *Config) (*Scene, error) {
 	s.window.SetCursorPosCallback(s.mouseMoveCallback)
 	s.window.SetCharCallback(s.charCallback)
 	s.window.SetScrollCallback(s.scrollCallback)
+	s.window.SetDropCallback(s.dropCallback)
 
 	// Make sure all components are initialized to the
 	// correct dimensions.
 	w, h := s.window.GetFramebufferSize()
 	s.resizeCallback(nil, w, h)
 
+	if len(s.file) == 0 {
+		s.file = defaultFile
+	} else if _, err := os.Stat(s.file); err == nil {
+		if err := s.load(); err != nil {
+			s.Release()
+			return nil, err
+		}
+	}
+
+	// A snapshot is only needed once the circuit differs from the file.
+	s.savedRev = s.revision()
+
+	// Snapshots left behind by an earlier session mean it did not
+	// end normally. Offer to restore the most recent one.
+	if dir := recoveryDir(); len(dir) > 0 {
+		s.recovery = NewRecovery(dir, c.AutosaveInterval, c.AutosaveKeep)
+
+		if s.pending, err = s.recovery.Latest(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+
+		s.recovery.Protect(s.pending)
+	}
+
 	s.panel.Clear()
 	return &s, nil
 }
 
+// StoreConfig copies the current window geometry and view settings
+// into the scene's configuration.
+func (s *Scene) StoreConfig() {
+	c := s.config
+
+	// The window size in fullscreen mode is that of the monitor.
+	// Keep the windowed geometry instead.
+	if !c.Fullscreen {
+		w, h := s.window.GetSize()
+		if w > 0 && h > 0 {
+			c.Width, c.Height = uint(w), uint(h)
+		}
+
+		c.X, c.Y = s.window.GetPos()
+		c.Positioned = true
+	}
+
+	c.Theme = ui.CurrentTheme().Name
+	c.StepInterval = sim.StepInterval()
+	c.GridVisible = s.canvas.GridVisible()
+	c.InfoVisible = s.infoVisible
+}
+
 func (s *Scene) Release() {
+	if err := s.stopRecording(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	// Ending normally, so this session's work needs no recovery.
+	if s.recovery != nil {
+		if err := s.recovery.Discard(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	if s.canvas != nil {
+		s.canvas.Release()
+	}
+
+	s.annotations.Release()
 	s.panel.Release()
+	s.statusBar.Release()
+	ui.Release()
 	resources.Release()
 	s.window.Release()
 }
@@ -75,48 +234,410 @@ func (s *Scene) Update() bool {
 
 	sim.Step(false)
 
+	s.canvas.Update()
 	s.canvas.SetPanning(s.window.GetKey(glfw.KeySpace) == glfw.Press)
+	s.updateRecording()
+	s.autosave()
+	s.updateDiff()
 	s.updateInfo()
+	s.updateStatus()
 	return ok
 }
 
 // Draw renders the scene.
 func (s *Scene) Draw() {
+	bg := ui.CurrentTheme().Background
+	gl.ClearColor(float32(bg.R)/255, float32(bg.G)/255, float32(bg.B)/255, 1)
 	gl.Clear(gl.COLOR_BUFFER_BIT)
 
 	s.canvas.Draw(s.projection)
+	s.heatmap.Draw(s.projection, s.canvas.Canvas)
+	s.diffView.Draw(s.projection, s.canvas.Canvas)
+	s.annotations.Draw(s.projection, s.canvas.Canvas)
+	s.minimap.Draw(s.projection, s.canvas.Canvas)
 
 	if s.infoVisible {
 		s.panel.Draw(s.projection)
 	}
+
+	s.statusBar.Draw(s.projection)
+}
+
+// circuit returns the current circuit and its editor data.
+func (s *Scene) circuit() *circuit.Circuit {
+	c := circuit.Circuit{
+		Cells:       sim.Cells(),
+		Annotations: s.annotations.List(),
+		Meta:        s.meta,
+	}
+
+	for slot := 0; slot < 10; slot++ {
+		if b, ok := s.bookmarks[slot]; ok {
+			c.Bookmarks = append(c.Bookmarks, b)
+		}
+	}
+
+	return &c
+}
+
+// setCircuit replaces the circuit and its editor data with c.
+func (s *Scene) setCircuit(c *circuit.Circuit) {
+	s.canvas.SelectionClear()
+	sim.Replace(c.Cells)
+	s.annotations.SetList(c.Annotations)
+	s.meta = c.Meta
+
+	s.bookmarks = make(map[int]circuit.Bookmark)
+	for _, b := range c.Bookmarks {
+		s.bookmarks[b.Slot] = b
+	}
+}
+
+// save writes the circuit and its editor data to the current file.
+func (s *Scene) save() error {
+	if err := circuit.SaveFile(s.file, s.circuit()); err != nil {
+		return err
+	}
+
+	s.config.AddRecent(s.file)
+	return nil
+}
+
+// load replaces the circuit and its editor data with the contents
+// of the current file.
+func (s *Scene) load() error {
+	c, err := circuit.LoadFile(s.file)
+	if err != nil {
+		return err
+	}
+
+	s.setCircuit(c)
+	s.config.AddRecent(s.file)
+	return nil
+}
+
+// revision returns a value which changes whenever the circuit or its
+// editor data do. Comparing it is much cheaper than comparing contents.
+// Bookmarks and metadata are also replaced along with the cells, which
+// counts as an edit.
+func (s *Scene) revision() [4]uint64 {
+	return [4]uint64{sim.Edits(), sim.Generation(), s.annotations.Revision(), s.bookmarkRev}
+}
+
+// autosave writes a recovery snapshot, if one is due and anything
+// changed since the last one.
+func (s *Scene) autosave() {
+	if s.recovery == nil {
+		return
+	}
+
+	if err := s.recovery.Heartbeat(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if !s.recovery.Due() || s.revision() == s.savedRev {
+		return
+	}
+
+	s.savedRev = s.revision()
+	if err := s.recovery.Save(s.file, s.circuit()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// toggleDiff shows or hides the differences between the simulation and
+// the current file, as it was last saved.
+func (s *Scene) toggleDiff() error {
+	if s.diffView.Visible() {
+		s.diffView.SetVisible(false)
+		s.diffBase = nil
+		return nil
+	}
+
+	return s.showDiff(s.file)
+}
+
+// showDiff shows the differences between the simulation and the given
+// circuit file, which may be in any supported format.
+func (s *Scene) showDiff(name string) error {
+	c, err := circuit.LoadFile(name)
+	if err != nil {
+		return err
+	}
+
+	c.Cells.Sort()
+	s.diffBase = c.Cells
+	s.diffStale = true
+	s.diffView.SetVisible(true)
+	s.updateDiff()
+	return nil
+}
+
+// toggleDiffSignals determines whether electron heads and tails count
+// as differences. By default, only the wiring is compared.
+func (s *Scene) toggleDiffSignals() {
+	s.diffSignals = !s.diffSignals
+	s.diffStale = true
+}
+
+// updateDiff compares the simulation with the file, while the
+// differences are visible. The comparison is only redone when the
+// cells have been edited, or, when signals are compared, when the
+// simulation has advanced.
+func (s *Scene) updateDiff() {
+	if !s.diffView.Visible() {
+		return
+	}
+
+	rev := [2]uint64{sim.Edits(), 0}
+	if s.diffSignals {
+		rev[1] = sim.Generation()
+	}
+
+	if !s.diffStale && rev == s.diffRev {
+		return
+	}
+
+	s.diffRev = rev
+	s.diffStale = false
+
+	if s.diffSignals {
+		s.diffView.SetChanges(diff.Compare(s.diffBase, sim.Cells()))
+	} else {
+		s.diffView.SetChanges(diff.CompareWires(s.diffBase, sim.Cells()))
+	}
+}
+
+// restoreRecovery replaces the circuit with the pending recovery
+// snapshot, and removes all snapshots of earlier sessions.
+func (s *Scene) restoreRecovery() error {
+	if s.pending == nil {
+		return nil
+	}
+
+	c, err := s.pending.Load()
+	if err != nil {
+		return err
+	}
+
+	s.setCircuit(c)
+	if len(s.pending.File) > 0 {
+		s.file = s.pending.File
+	}
+
+	s.pending = nil
+	return s.recovery.DiscardOthers()
+}
+
+// discardRecovery removes all snapshots of earlier sessions.
+func (s *Scene) discardRecovery() error {
+	if s.pending == nil {
+		return nil
+	}
+
+	s.pending = nil
+	return s.recovery.DiscardOthers()
+}
+
+// siblingFile returns the name of the current circuit file, with its
+// extension replaced by the given suffix.
+func (s *Scene) siblingFile(suffix string) string {
+	return strings.TrimSuffix(s.file, filepath.Ext(s.file)) + suffix
+}
+
+// importImage reads cells from the given image file into the clipboard.
+func (s *Scene) importImage(name string) error {
+	c := s.config
+	cells, err := circuit.ImportImageFile(name, c.ImageColors, c.ImageTolerance)
+	if err != nil {
+		return err
+	}
+
+	s.canvas.SetClipboard(cells)
+	return nil
+}
+
+// exportImage writes the selected cells, or all cells if nothing is
+// selected, to the given PNG file.
+func (s *Scene) exportImage(name string) error {
+	c := s.config
+	return circuit.ExportImageFile(name, sim.Cells(), s.canvas.SelectionBounds(),
+		c.ImageScale, c.ImageColors)
+}
+
+// exportSVG writes the selected cells, or all cells if nothing is
+// selected, to the given SVG file. The diagram uses the current theme
+// and includes the annotations, grid and wire endpoint markers.
+func (s *Scene) exportSVG(name string) error {
+	cells := sim.Cells()
+	if s.canvas.SelectionLen() > 0 {
+		cells = s.canvas.Selection()
+	}
+
+	t := ui.CurrentTheme()
+	opt := circuit.SVGOptions{
+		Area:        s.canvas.SelectionBounds(),
+		CellSize:    svgCellSize,
+		Colors:      circuit.ColorMap(t.Cells),
+		Grid:        s.canvas.GridVisible(),
+		GridColor:   t.Grid,
+		Ports:       true,
+		PortColor:   t.Selection,
+		Annotations: s.annotations.List(),
+	}
+
+	return circuit.SaveSVGFile(name, cells, &opt)
+}
+
+// toggleRecording starts or stops recording of the simulation.
+// The selected area is recorded, or the visible area if there is no
+// selection. A frame is added for every generation.
+func (s *Scene) toggleRecording() error {
+	if s.recorder != nil {
+		return s.stopRecording()
+	}
+
+	area := s.canvas.SelectionBounds()
+	if area.Empty() {
+		area = s.canvas.VisibleCells()
+	}
+
+	// PNG sequences are named by a Sprintf pattern, so any '%' in the
+	// file name must be escaped.
+	name := strings.Replace(s.siblingFile(""), "%", "%%", -1) + "-%05d.png"
+	if s.config.RecordFormat == record.FormatGIF {
+		name = s.siblingFile(".gif")
+	}
+
+	// Frames are drawn at the canvas zoom level, so recording the
+	// visible area yields frames the size of the viewport.
+	rec, err := record.New(name, area, s.canvas.Zoom(), s.config.ImageColors, sim.StepInterval())
+	if err != nil {
+		return err
+	}
+
+	s.recorder = rec
+	s.recordGen = sim.Generation()
+	return rec.Add(sim.Cells())
+}
+
+// stopRecording finishes the current recording, if any.
+func (s *Scene) stopRecording() error {
+	if s.recorder == nil {
+		return nil
+	}
+
+	err := s.recorder.Close()
+	s.recorder = nil
+	return err
+}
+
+// updateRecording adds a frame to the current recording whenever
+// the simulation has advanced. Recording stops on error, keeping the
+// frames recorded so far.
+func (s *Scene) updateRecording() {
+	if s.recorder == nil || s.recordGen == sim.Generation() {
+		return
+	}
+
+	s.recordGen = sim.Generation()
+	if err := s.recorder.Add(sim.Cells()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		if err := s.stopRecording(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+// isImageFile returns true if the given file has a supported
+// image file extension.
+func isImageFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// storeBookmark stores the current camera position in the given slot.
+func (s *Scene) storeBookmark(slot int) {
+	x, y, zoom := s.canvas.Center()
+	s.bookmarks[slot] = circuit.Bookmark{
+		Slot: slot,
+		X:    x,
+		Y:    y,
+		Zoom: zoom,
+	}
+	s.bookmarkRev++
+}
+
+// recallBookmark moves the camera to the position stored in the given
+// slot. This does nothing if the slot is empty.
+func (s *Scene) recallBookmark(slot int) {
+	if b, ok := s.bookmarks[slot]; ok {
+		s.canvas.SetCenter(b.X, b.Y, b.Zoom)
+	}
 }
 
 // drawCells draws on the grid. What is being drawn depends on the current mode.
 func (s *Scene) drawCells() {
-	if s.lmbPressed {
+	if s.lmbPressed && s.drawMode == drawModeDraw {
 		x, y := s.canvas.HoverTarget()
 		sim.Set(x, y, int32(s.currentTool))
 	}
 }
 
+// fillCells fills the region under the mouse cursor with the current
+// tool, if we are in one of the fill modes.
+func (s *Scene) fillCells() {
+	x, y := s.canvas.HoverTarget()
+
+	switch s.drawMode {
+	case drawModeFill:
+		sim.Fill(x, y, int32(s.currentTool))
+	case drawModeFillNet:
+		sim.FillNet(x, y, int32(s.currentTool))
+	}
+}
+
 // setTool sets the current drawing tool.
 func (s *Scene) setTool(t int) {
 	s.currentTool = t
 }
 
+// cycleDrawMode switches to the next drawing mode.
+func (s *Scene) cycleDrawMode() {
+	s.drawMode = (s.drawMode + 1) % 3
+}
+
 func (s *Scene) scrollCallback(_ *glfw.Window, x, y float64) {
 	s.canvas.Scroll(x, y)
 }
 
 func (s *Scene) mouseMoveCallback(_ *glfw.Window, x, y float64) {
 	s.canvas.MouseMove(x, y)
+
+	if s.minimap.MouseMove(s.canvas.Canvas) {
+		return
+	}
+
 	s.drawCells()
 }
 
 func (s *Scene) mouseButtonCallback(_ *glfw.Window, button glfw.MouseButton, action glfw.Action, mod glfw.ModifierKey) {
+	if s.minimap.MouseButton(s.canvas.Canvas, button, action) {
+		s.lmbPressed = false
+		return
+	}
+
 	s.canvas.MouseButton(button, action, mod)
 	s.lmbPressed = (button == glfw.MouseButton1 && action == glfw.Press)
 	s.drawCells()
+
+	if s.lmbPressed {
+		s.fillCells()
+	}
 }
 
 func (s *Scene) resizeCallback(_ *glfw.Window, w, h int) {
@@ -124,19 +645,40 @@ func (s *Scene) resizeCallback(_ *glfw.Window, w, h int) {
 
 	s.projection = util.Mat4Ortho(0, float32(w), 0, float32(h), -1, 1)
 	s.canvas.Resize(w, h)
-	s.panel.Resize(0, 0, util.Max(w/5, 280), h)
+	sh := s.statusBar.Height()
+	s.statusBar.Resize(w, h)
+	s.panel.Resize(0, 0, util.Max(w/5, 280), h-sh)
+	s.minimap.Resize(w-minimapWidth-10, h-sh-minimapHeight-10, minimapWidth, minimapHeight)
 }
 
-func (s *Scene) charCallback(_ *glfw.Window, char rune) {
+// dropCallback handles files which are dropped onto the window. Cells
+// from images end up in the clipboard, ready to be pasted. Any other
+// file is taken to be a circuit, which the simulation is compared with.
+func (s *Scene) dropCallback(_ *glfw.Window, names []string) {
+	for _, name := range names {
+		var err error
+		if isImageFile(name) {
+			err = s.importImage(name)
+		} else {
+			err = s.showDiff(name)
+		}
 
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+func (s *Scene) charCallback(_ *glfw.Window, char rune) {
+	s.annotations.Char(char)
 }
 
 func (s *Scene) keyCallback(_ *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
 	switch action {
 	case glfw.Release:
 		s.keyRelease(key, scancode, mods)
-	case glfw.Press:
-		s.keyPress(key, scancode, mods)
+	case glfw.Press, glfw.Repeat:
+		s.keyPress(key, scancode, mods, action == glfw.Repeat)
 	}
 }
 
@@ -147,80 +689,81 @@ func (s *Scene) keyRelease(key glfw.Key, scancode int, mods glfw.ModifierKey) {
 	}
 }
 
-func (s *Scene) keyPress(key glfw.Key, scancode int, mods glfw.ModifierKey) {
-	switch key {
-	case glfw.KeyEscape:
-		s.canvas.SelectionClear()
-		s.canvas.ClipboardClear()
-	case glfw.KeyF1:
-		s.canvas.ToggleGridVisible()
-	case glfw.KeyF2:
-		s.canvas.ToggleDrawClipboard()
-
-	case glfw.KeyGraveAccent:
-		s.infoVisible = !s.infoVisible
-
-	case glfw.KeyQ:
-		sim.ToggleRunning()
-	case glfw.KeyE:
-		sim.Step(true)
-	case glfw.KeyT:
-		sim.Trim()
-
-	case glfw.Key1:
-		s.setTool(sim.CellEmpty)
-	case glfw.Key2:
-		s.setTool(sim.CellWire)
-	case glfw.Key3:
-		s.setTool(sim.CellHead)
-	case glfw.Key4:
-		s.setTool(sim.CellTail)
-
-	case glfw.KeyEqual:
-		sim.ScaleInterval(-1)
-	case glfw.KeyMinus:
-		sim.ScaleInterval(+1)
+// keyPress runs the actions bound to the given key. While an annotation
+// is being edited, only actions which apply to the editor are run. Key
+// repeats are only passed on to the editor.
+func (s *Scene) keyPress(key glfw.Key, scancode int, mods glfw.ModifierKey, repeat bool) {
+	editing := s.annotations.Editing()
 
-	case glfw.KeyLeftShift, glfw.KeyRightShift:
-		s.canvas.SetAddSelection(true)
-	case glfw.KeyA:
-		if mods&glfw.ModControl != 0 {
-			s.canvas.SelectAll()
-		}
-	case glfw.KeyDelete:
-		s.canvas.SelectionDelete()
-	case glfw.KeyUp:
-		s.canvas.SelectionMove(0, -1)
-	case glfw.KeyDown:
-		s.canvas.SelectionMove(0, +1)
-	case glfw.KeyLeft:
-		s.canvas.SelectionMove(-1, 0)
-	case glfw.KeyRight:
-		s.canvas.SelectionMove(+1, 0)
-
-	case glfw.KeyC:
-		if mods&glfw.ModControl != 0 {
-			s.canvas.ClipboardCopy()
+	if !editing {
+		if repeat {
+			return
 		}
-	case glfw.KeyV:
-		if mods&glfw.ModControl != 0 {
-			s.canvas.ClipboardPaste()
-		} else {
-			s.canvas.ScrollTo(0, 0)
-			s.canvas.SetZoom(ui.ZoomDefault)
+
+		switch key {
+		case glfw.KeyLeftShift, glfw.KeyRightShift:
+			s.canvas.SetAddSelection(true)
 		}
-	case glfw.KeyX:
-		if mods&glfw.ModControl != 0 {
-			s.canvas.ClipboardCut()
+	}
+
+	for _, name := range s.keymap.Actions(key, mods) {
+		if a := findAction(name); a != nil && a.Editing == editing {
+			a.Run(s)
 		}
+	}
+}
+
+// updateStatus recreates the status bar text and the tooltip for
+// whatever is under the mouse cursor.
+func (s *Scene) updateStatus() {
+	x, y := s.canvas.HoverTarget()
+	cells := sim.Cells()
 
-	case glfw.KeyZ:
-		if mods&glfw.ModControl != 0 && mods&glfw.ModShift == 0 {
-			s.history.Undo()
-		} else if mods&glfw.ModControl != 0 && mods&glfw.ModShift != 0 {
-			s.history.Redo()
+	state := sim.CellEmpty
+	if n := cells.IndexOf(x, y); n > -1 {
+		state = int(cells[n+2])
+	}
+
+	sel := "none"
+	if b := s.canvas.SelectionBounds(); !b.Empty() {
+		sel = fmt.Sprintf("%d cells, %dx%d at %d,%d", s.canvas.SelectionLen(),
+			b.Dx(), b.Dy(), b.Min.X, b.Min.Y)
+	}
+
+	rec := ""
+	if s.recorder != nil {
+		rec = fmt.Sprintf("REC %d frames | ", s.recorder.Frames())
+	}
+
+	if s.pending != nil {
+		s.statusBar.Print("Unsaved work from %s found (%s). [%s] Restore, [%s] Discard",
+			s.pending.Time.Format("2006-01-02 15:04"), filepath.Base(s.pending.File),
+			s.helpKeys([]*Action{findAction("recovery.restore")}),
+			s.helpKeys([]*Action{findAction("recovery.discard")}))
+	} else {
+		s.statusBar.Print("%sCursor: %d,%d (%s) | Selection: %s | Generation: %d | %.1f steps/s",
+			rec, x, y, toolName(state), sel, sim.Generation(), s.statusBar.StepRate())
+	}
+
+	// Describe annotations and cell activity under the cursor.
+	var tip []string
+	if i := s.annotations.At(x, y); i > -1 {
+		tip = append(tip, s.annotations.Get(i).Text)
+	}
+
+	if s.diffView.Visible() {
+		if ch := diff.Find(s.diffView.Changes(), x, y); ch != nil {
+			tip = append(tip, fmt.Sprintf("Diff: %v", ch))
 		}
 	}
+
+	if s.heatmap.Visible() && state != sim.CellEmpty {
+		tip = append(tip, fmt.Sprintf("Heads: %d in %d generations",
+			sim.Heat(x, y), sim.HeatmapWindow()))
+	}
+
+	mx, my := s.canvas.MousePosition()
+	s.statusBar.SetTooltip(mx, my, tip...)
 }
 
 // updateInfo recreates the text contents of the debug/info panel.
@@ -236,41 +779,95 @@ func (s *Scene) updateInfo() {
 		line++
 	}
 
+	p("File: %s", s.file)
 	p("Cells: %d, running: %v", sim.CellCount(), sim.Running())
-	p("Step interval: %s", sim.StepInterval())
-	p("Current tool: %s", toolName(s.currentTool))
+	p("Step interval: %s, snapshot: %v", sim.StepInterval(), sim.HasSnapshot())
+	p("Current tool: %s (%s)", toolName(s.currentTool), drawModeName(s.drawMode))
+	p("Theme: %s", ui.CurrentTheme().Name)
 
-	p("")
-	p("Simulation:")
-	p(" [q] Start/stop simulation")
-	p(" [e] Single simulation step")
-	p(" [+] Double simulation speed")
-	p(" [-] Halve simulation speed")
+	if s.diffView.Visible() {
+		added, removed, changed := diff.Count(s.diffView.Changes())
+		p("Diff: %d added, %d removed, %d changed", added, removed, changed)
+	}
 
-	p("")
-	p("Tools:")
-	p(" [1] Draw Empty cell")
-	p(" [2] Draw Wire cell")
-	p(" [3] Draw Electron head")
-	p(" [4] Draw Electron tail")
-	p(" [t] Trim empty cells")
-	p(" [ctrl-a] Select all cells")
-	p(" [ctrl-x] Cut selection")
-	p(" [ctrl-c] Copy selection")
-	p(" [ctrl-v] Paste selection")
-	p(" [del] Delete selection")
-	p(" [arrow keys] Move selection")
+	if s.heatmap.Visible() {
+		p("Heatmap: %d generations, peak: %d heads", sim.HeatmapWindow(), s.heatmap.Max())
+	}
+
+	s.printHelp(p)
 
 	p("")
-	p("Misc:")
-	p(" [~] Show/hide this info panel")
-	p(" [F1] Toggle grid visibility")
-	p(" [F2] Toggle clipboard visibility")
-	p(" [esc] Cancel selection / Clear clipboard")
+	p("Mouse:")
 	p(" [lmb] Draw cells")
 	p(" [rmb] Draw selection")
+	p(" [shift+rmb] Add to selection")
+	p(" [ctrl-rmb] Select connected net")
+	p(" [ctrl-alt-rmb] Select net up to junctions")
 	p(" [wheel] Zoom in/out")
 	p(" [space+mouse] Pan viewport")
+	p(" [drop image] Import PNG/GIF into clipboard")
+}
+
+// printHelp lists all bound actions along with their keys, by group.
+func (s *Scene) printHelp(p func(string, ...interface{})) {
+	var groups []string
+	seen := make(map[string]bool)
+	for _, a := range actions {
+		if !seen[a.Group] {
+			seen[a.Group] = true
+			groups = append(groups, a.Group)
+		}
+	}
+
+	for _, g := range groups {
+		p("")
+		p("%s:", g)
+
+		// Consecutive actions with the same description are listed
+		// as a single entry.
+		var run []*Action
+		flush := func() {
+			if len(run) > 0 {
+				p(" [%s] %s", s.helpKeys(run), run[0].Help)
+			}
+			run = run[:0]
+		}
+
+		for _, a := range actions {
+			if a.Group != g || len(s.keymap.Keys(a.Name)) == 0 {
+				continue
+			}
+
+			if len(run) > 0 && run[0].Help != a.Help {
+				flush()
+			}
+			run = append(run, a)
+		}
+
+		flush()
+	}
+}
+
+// helpKeys returns a description of the keys bound to the given actions.
+func (s *Scene) helpKeys(set []*Action) string {
+	var keys []string
+
+	if len(set) == 1 {
+		for _, kc := range s.keymap.Keys(set[0].Name) {
+			keys = append(keys, kc.String())
+		}
+		return strings.Join(keys, "/")
+	}
+
+	for _, a := range set {
+		keys = append(keys, s.keymap.Keys(a.Name)[0].String())
+	}
+
+	if len(keys) > 4 {
+		return keys[0] + ".." + keys[len(keys)-1]
+	}
+
+	return strings.Join(keys, "/")
 }
 
 // toolName returns a human-readable name for the given cell state.
@@ -286,3 +883,15 @@ func toolName(v int) string {
 		return "Empty"
 	}
 }
+
+// drawModeName returns a human-readable name for the given drawing mode.
+func drawModeName(v int) string {
+	switch v {
+	case drawModeFill:
+		return "fill"
+	case drawModeFillNet:
+		return "net fill"
+	default:
+		return "draw"
+	}
+}
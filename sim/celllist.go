@@ -1,6 +1,7 @@
 package sim
 
 import (
+	"image"
 	"sort"
 )
 
@@ -88,6 +89,29 @@ func (c CellList) Less(i, j int) bool {
 	return (xa < xb) || ((xa == xb) && (ya < yb))
 }
 
+// Bounds returns the smallest rectangle which encloses all non-empty
+// cells in the list. Returns an empty rectangle if there are none.
+func (c CellList) Bounds() image.Rectangle {
+	var r image.Rectangle
+	var found bool
+
+	for i := 0; i < len(c)-2; i += 3 {
+		if c[i+2] == CellEmpty {
+			continue
+		}
+
+		cr := image.Rect(int(c[i]), int(c[i+1]), int(c[i])+1, int(c[i+1])+1)
+		if !found {
+			r = cr
+			found = true
+		} else {
+			r = r.Union(cr)
+		}
+	}
+
+	return r
+}
+
 // Contains returns true if the cell with coordinates X/Y exists in the set.
 func (c CellList) Contains(x, y int32) bool {
 	return c.IndexOf(x, y) > -1
@@ -123,3 +147,138 @@ func (c CellList) IndexOf(x, y int32) int {
 
 	return n
 }
+
+// Region returns the indices of all cells which are 8-connected to the
+// cell at x/y and for which match returns true. The starting cell must
+// itself match, or the result is empty.
+//
+// This performs a breadth-first search and assumes c is sorted.
+func (c CellList) Region(x, y int32, match func(state int32) bool) []int {
+	return c.region(x, y, match, nil)
+}
+
+// Net returns the indices of all non-empty cells which are 8-connected to
+// the cell at x/y. These are all cells which are electrically connected to
+// the starting cell.
+//
+// If stopAtJunctions is true, the search includes junction cells, but does
+// not continue past them. See IsJunction for details. This allows selection
+// of a single signal route, up to the diodes or gates it connects to.
+func (c CellList) Net(x, y int32, stopAtJunctions bool) []int {
+	notEmpty := func(v int32) bool {
+		return v != CellEmpty
+	}
+
+	if !stopAtJunctions {
+		return c.region(x, y, notEmpty, nil)
+	}
+
+	start := c.IndexOf(x, y)
+	return c.region(x, y, notEmpty, func(n int) bool {
+		return n == start || !c.IsJunction(c[n], c[n+1])
+	})
+}
+
+// IsJunction returns true if the cell at x/y connects more than two
+// separate branches of wire. A plain wire has at most two groups of
+// neighbours: one on either side. The entry points of diodes and the
+// places where gate inputs merge have three or more.
+func (c CellList) IsJunction(x, y int32) bool {
+	var ring [8][2]int32
+	var count int
+
+	for dy := int32(-1); dy <= 1; dy++ {
+		for dx := int32(-1); dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+
+			n := c.IndexOf(x+dx, y+dy)
+			if n > -1 && c[n+2] != CellEmpty {
+				ring[count] = [2]int32{dx, dy}
+				count++
+			}
+		}
+	}
+
+	if count < 3 {
+		return false
+	}
+
+	// Count the 8-connected groups among the neighbours.
+	var group [8]int
+	var groups int
+
+	for i := 0; i < count; i++ {
+		if group[i] > 0 {
+			continue
+		}
+
+		groups++
+		group[i] = groups
+		stack := []int{i}
+
+		for len(stack) > 0 {
+			a := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			for b := 0; b < count; b++ {
+				if group[b] > 0 || !adjacent(ring[a], ring[b]) {
+					continue
+				}
+
+				group[b] = groups
+				stack = append(stack, b)
+			}
+		}
+	}
+
+	return groups > 2
+}
+
+// adjacent returns true if the two given cell coordinates touch each
+// other horizontally, vertically or diagonally.
+func adjacent(a, b [2]int32) bool {
+	dx, dy := a[0]-b[0], a[1]-b[1]
+	return dx >= -1 && dx <= 1 && dy >= -1 && dy <= 1
+}
+
+// region performs a breadth-first search for all cells which are
+// 8-connected to the cell at x/y and for which match returns true.
+// If expand is not nil, the neighbours of a matching cell are only
+// visited if expand returns true for that cell's index.
+func (c CellList) region(x, y int32, match func(state int32) bool, expand func(n int) bool) []int {
+	n := c.IndexOf(x, y)
+	if n < 0 || !match(c[n+2]) {
+		return nil
+	}
+
+	visited := map[int]bool{n: true}
+	queue := []int{n}
+
+	for i := 0; i < len(queue); i++ {
+		if expand != nil && !expand(queue[i]) {
+			continue
+		}
+
+		cx, cy := c[queue[i]], c[queue[i]+1]
+
+		for dy := int32(-1); dy <= 1; dy++ {
+			for dx := int32(-1); dx <= 1; dx++ {
+				if dx == 0 && dy == 0 {
+					continue
+				}
+
+				n = c.IndexOf(cx+dx, cy+dy)
+				if n < 0 || visited[n] || !match(c[n+2]) {
+					continue
+				}
+
+				visited[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+
+	return queue
+}
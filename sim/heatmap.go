@@ -0,0 +1,90 @@
+package sim
+
+// heatKey identifies a cell in the heatmap by its coordinates.
+type heatKey struct {
+	x, y int32
+}
+
+// heatmap counts how often each cell has been an electron head, over
+// a sliding window of the most recent generations.
+//
+// Cell coordinates are used as keys instead of cell indices, because
+// the latter change whenever cells are added, removed or sorted.
+type heatmap struct {
+	window int         // Number of generations to track. 0 disables tracking.
+	ring   [][]heatKey // Heads for each generation in the window.
+	next   int         // Ring index for the next generation.
+	counts map[heatKey]int
+}
+
+// SetWindow sets the number of generations tracked by the heatmap.
+// This clears all existing counts. A value of 0 disables tracking.
+func (h *heatmap) SetWindow(n int) {
+	if n < 0 {
+		n = 0
+	}
+
+	h.window = n
+	h.Reset()
+}
+
+// Reset clears all counts.
+func (h *heatmap) Reset() {
+	h.next = 0
+	h.ring = nil
+	h.counts = nil
+
+	if h.window > 0 {
+		h.ring = make([][]heatKey, h.window)
+		h.counts = make(map[heatKey]int)
+	}
+}
+
+// Record adds the electron heads in the given cells as a new generation.
+// The oldest generation is dropped if the window is full.
+func (h *heatmap) Record(cells CellList) {
+	if h.window == 0 {
+		return
+	}
+
+	// Drop the oldest generation, reusing its buffer.
+	heads := h.ring[h.next]
+	for _, k := range heads {
+		if h.counts[k] <= 1 {
+			delete(h.counts, k)
+		} else {
+			h.counts[k]--
+		}
+	}
+
+	heads = heads[:0]
+	for i := 0; i < len(cells)-2; i += 3 {
+		if cells[i+2] == CellHead {
+			k := heatKey{cells[i], cells[i+1]}
+			heads = append(heads, k)
+			h.counts[k]++
+		}
+	}
+
+	h.ring[h.next] = heads
+	h.next = (h.next + 1) % h.window
+}
+
+// Count returns the number of generations in the window in which
+// the cell at x/y was an electron head.
+func (h *heatmap) Count(x, y int32) int {
+	return h.counts[heatKey{x, y}]
+}
+
+// List returns all cells with a non-zero count. Each entry holds the
+// cell's X and Y coordinates and its count, in place of a cell state.
+// The list is sorted.
+func (h *heatmap) List() CellList {
+	out := make(CellList, 0, len(h.counts)*3)
+	for k, v := range h.counts {
+		out = append(out, k.x, k.y, int32(v))
+	}
+
+	out.Sort()
+	return out
+}
@@ -24,6 +24,9 @@ var (
 
 	// running determines if the simulation is currently running by itself.
 	running bool
+
+	// snapshot holds a copy of the cell data as stored by Snapshot.
+	snapshot CellList
 )
 
 // CellsChanged returns true if the cell buffer has changed since
@@ -55,6 +58,16 @@ func StepInterval() time.Duration {
 	return stepInterval
 }
 
+// SetStepInterval sets the step interval.
+// There is a lower bound of 1 microsecond.
+func SetStepInterval(v time.Duration) {
+	if v < time.Microsecond {
+		v = time.Microsecond
+	}
+
+	stepInterval = v
+}
+
 // ScaleInterval sets the new step interval by halving or doubling the
 // current value. There is a lower bound of 1 microsecond.
 // Delta is expected to be -1 or +1.
@@ -115,6 +128,13 @@ func Load(x, y int32, set CellList) {
 	data.Sort()
 }
 
+// Replace replaces all cells in the simulation with the given set.
+// The simulation takes ownership of set.
+func Replace(set CellList) {
+	set.Sort()
+	data.Replace(set)
+}
+
 // Unload removes all the given cells from the simulation.
 // This marks all existing cells as CellEmpty. To really
 // delete them, use the Trim() function afterwards.
@@ -133,6 +153,134 @@ func Set(x, y, state int32) {
 	data.Sort()
 }
 
+// Fill sets the 8-connected region of cells which share the state of
+// the cell at x/y, to the given state. This is done as a single batched
+// edit, so it is considerably cheaper than calling Set for each cell.
+//
+// Empty space is unbounded, so if there is no cell at x/y, or it is
+// CellEmpty, this call does nothing.
+func Fill(x, y, state int32) {
+	n := data.cellData.IndexOf(x, y)
+	if n < 0 {
+		return
+	}
+
+	target := data.cellData[n+2]
+	if target == CellEmpty || target == state {
+		return
+	}
+
+	data.SetIndices(data.cellData.Region(x, y, func(v int32) bool {
+		return v == target
+	}), state)
+}
+
+// FillNet sets every cell in the net which contains the cell at x/y,
+// to the given state. A net is the 8-connected region of non-empty cells.
+// This can be used to clear an entire wire, or to reset all electron
+// heads and tails on a wire back to CellWire.
+func FillNet(x, y, state int32) {
+	data.SetIndices(data.cellData.Region(x, y, func(v int32) bool {
+		return v != CellEmpty
+	}), state)
+}
+
+// Net returns a sorted copy of all cells in the net which contains the
+// cell at x/y. If stopAtJunctions is true, the net ends at diodes and
+// gates. Refer to CellList.Net for details.
+func Net(x, y int32, stopAtJunctions bool) CellList {
+	cd := data.cellData
+	set := cd.Net(x, y, stopAtJunctions)
+	out := make(CellList, 0, len(set)*3)
+
+	for _, n := range set {
+		out = append(out, cd[n], cd[n+1], cd[n+2])
+	}
+
+	out.Sort()
+	return out
+}
+
+// ClearSignals turns all electron heads and tails in the simulation
+// back into wire. This returns the circuit to its drawn state.
+func ClearSignals() {
+	data.ClearSignals(nil)
+}
+
+// ClearSignalsIn turns all electron heads and tails at the positions
+// of the given cells, back into wire.
+func ClearSignalsIn(set CellList) {
+	data.ClearSignals(set)
+}
+
+// Snapshot stores a copy of the current simulation state. It can be
+// restored with Restore. This replaces any previous snapshot.
+func Snapshot() {
+	snapshot = make(CellList, len(data.cellData))
+	copy(snapshot, data.cellData)
+}
+
+// HasSnapshot returns true if a snapshot has been stored.
+func HasSnapshot() bool {
+	return snapshot != nil
+}
+
+// Restore replaces the simulation state with the last snapshot.
+// The snapshot itself is kept, so it can be restored again.
+// This call does nothing if no snapshot has been stored.
+func Restore() {
+	if snapshot == nil {
+		return
+	}
+
+	set := make(CellList, len(snapshot))
+	copy(set, snapshot)
+	data.Replace(set)
+}
+
+// Generation returns the number of simulation steps performed so far.
+func Generation() uint64 {
+	return data.generation
+}
+
+// Edits returns a number which changes whenever the cells are edited.
+// Unlike CellsChanged, this ignores simulation steps and does not reset,
+// so multiple callers can each track their own changes.
+func Edits() uint64 {
+	return data.edits
+}
+
+// SetHeatmapWindow enables tracking of electron head activity over the
+// given number of most recent generations. A value of 0 disables it.
+// This clears any existing counts.
+func SetHeatmapWindow(n int) {
+	data.heat.SetWindow(n)
+}
+
+// HeatmapWindow returns the number of generations tracked by the
+// heatmap. Returns 0 if tracking is disabled.
+func HeatmapWindow() int {
+	return data.heat.window
+}
+
+// ResetHeatmap clears all heatmap counts.
+func ResetHeatmap() {
+	data.heat.Reset()
+}
+
+// Heat returns the number of generations in the heatmap window in which
+// the cell at x/y has been an electron head.
+func Heat(x, y int32) int {
+	return data.heat.Count(x, y)
+}
+
+// Heatmap returns all cells which have been an electron head within the
+// heatmap window. Each entry holds the X and Y coordinates of a cell and
+// its head count, in place of the cell state.
+func Heatmap() CellList {
+	return data.heat.List()
+}
+
 // Step applies the wireworld rules to the celldata once.
 // If force is true, this is done immediately and unconditionally.
 // If force is false, this call is ignored if not enough time has
@@ -25,6 +25,16 @@ type simulationData struct {
 	// a step call. This needs to be done when cells have been altered
 	// through Set().
 	staleNeighbours bool
+
+	// heat tracks electron head activity, if enabled.
+	heat heatmap
+
+	// generation counts the number of steps performed.
+	generation uint64
+
+	// edits counts the changes made to the cells, other than by
+	// simulation steps.
+	edits uint64
 }
 
 // CellCount returns the number of cells in the simulation.
@@ -48,10 +58,16 @@ func (s *simulationData) update() {
 		s.tempData = make(CellList, n)
 	}
 
-	s.cellsChanged = true
+	s.edited()
 	s.staleNeighbours = true
 }
 
+// edited marks the cells as changed by an edit.
+func (s *simulationData) edited() {
+	s.cellsChanged = true
+	s.edits++
+}
+
 // Sort sorts the cell list.
 func (s *simulationData) Sort() {
 	sort.Sort(s)
@@ -86,14 +102,16 @@ func (s *simulationData) UpdateList(v CellList) {
 		}
 	}
 
-	s.cellsChanged = true
+	s.edited()
 }
 
 func (s *simulationData) Set(x, y, state int32) {
 	n := s.cellData.IndexOf(x, y)
 	if n > -1 {
-		s.cellsChanged = s.cellData[n+2] != state
-		s.cellData[n+2] = state
+		if s.cellData[n+2] != state {
+			s.cellData[n+2] = state
+			s.edited()
+		}
 		return
 	}
 
@@ -105,10 +123,57 @@ func (s *simulationData) Set(x, y, state int32) {
 	s.cellData = append(s.cellData, x, y, state)
 	s.tempData = append(s.tempData, 0, 0, 0)
 	s.neighbours = append(s.neighbours, 0, 0, 0, 0, 0, 0, 0, 0)
-	s.cellsChanged = true
+	s.edited()
 	s.staleNeighbours = true
 }
 
+// Replace replaces all cell data with v. The list is expected to be sorted.
+// Heatmap counts are cleared, as they no longer apply to the new cells.
+func (s *simulationData) Replace(v CellList) {
+	s.cellData = v
+	s.heat.Reset()
+	s.update()
+}
+
+// ClearSignals turns electron heads and tails back into wire.
+// If set is nil, this applies to all cells. Otherwise only to the
+// cells at the positions of the cells in set.
+func (s *simulationData) ClearSignals(set CellList) {
+	cd := s.cellData
+
+	clear := func(n int) {
+		if cd[n+2] == CellHead || cd[n+2] == CellTail {
+			cd[n+2] = CellWire
+			s.edited()
+		}
+	}
+
+	if set == nil {
+		for n := 0; n < len(cd)-2; n += 3 {
+			clear(n)
+		}
+		return
+	}
+
+	for i := 0; i < len(set)-2; i += 3 {
+		if n := cd.IndexOf(set[i], set[i+1]); n > -1 {
+			clear(n)
+		}
+	}
+}
+
+// SetIndices sets all cells at the given cell data indices to state.
+// The cell coordinates do not change, so this requires no re-sorting
+// and leaves the neighbour lookup table intact.
+func (s *simulationData) SetIndices(set []int, state int32) {
+	for _, n := range set {
+		if s.cellData[n+2] != state {
+			s.cellData[n+2] = state
+			s.edited()
+		}
+	}
+}
+
 // Step performs a single simulation step by applying the Wireworld rules to the cell data.
 func (s *simulationData) Step() {
 	// Recompute neighbours if necessary.
@@ -161,6 +226,8 @@ func (s *simulationData) Step() {
 	s.tempData = t0
 
 	s.cellsChanged = true
+	s.generation++
+	s.heat.Record(s.cellData)
 }
 
 // computeNeighbours recomputes all neighbours for all cells.
@@ -0,0 +1,249 @@
+package ui
+
+import (
+	"image/color"
+
+	"wireworld/circuit"
+	"wireworld/resources"
+	"wireworld/util"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+const (
+	// annotationFontSize defines the size, in pixels, at which annotation
+	// glyphs are rasterised. They are scaled to the zoom level when drawn.
+	annotationFontSize = 48
+
+	// AnnotationSizeDefault defines the default line height of new
+	// annotations, in cells.
+	AnnotationSizeDefault = 4
+)
+
+// annotationPalette defines the colours an annotation can cycle through.
+var annotationPalette = []color.NRGBA{
+	{0x20, 0x20, 0x20, 0xff},
+	{0xd0, 0x20, 0x20, 0xff},
+	{0x00, 0x7a, 0xcc, 0xff},
+	{0x20, 0x90, 0x20, 0xff},
+	{0x98, 0x00, 0xff, 0xff},
+}
+
+// Annotations maintains and renders a set of text labels. These live in
+// world coordinates, so they move and scale along with the cells.
+type Annotations struct {
+	font     *Font
+	list     []circuit.Annotation
+	vertices []float32
+	editing  int    // Index of the annotation being edited, or -1.
+	changed  bool   // Mesh needs to be rebuilt?
+	revision uint64 // Incremented on every change.
+}
+
+// NewAnnotations creates a new, empty annotation layer.
+func NewAnnotations() *Annotations {
+	return &Annotations{
+		font:    NewFont(fontRegularTTF, annotationFontSize),
+		editing: -1,
+		changed: true,
+	}
+}
+
+// Release clears annotation resources.
+func (a *Annotations) Release() {
+	if a == nil {
+		return
+	}
+
+	a.font.Release()
+	a.list = nil
+}
+
+// List returns a copy of all annotations.
+func (a *Annotations) List() []circuit.Annotation {
+	out := make([]circuit.Annotation, len(a.list))
+	copy(out, a.list)
+	return out
+}
+
+// SetList replaces all annotations with a copy of v.
+// This cancels any edit in progress.
+func (a *Annotations) SetList(v []circuit.Annotation) {
+	a.list = make([]circuit.Annotation, len(v))
+	copy(a.list, v)
+	a.editing = -1
+	a.modified()
+}
+
+// Revision returns a number which changes whenever the annotations do.
+func (a *Annotations) Revision() uint64 {
+	return a.revision
+}
+
+// modified marks the annotations as changed.
+func (a *Annotations) modified() {
+	a.changed = true
+	a.revision++
+}
+
+// Get returns the annotation at the given index.
+func (a *Annotations) Get(i int) circuit.Annotation {
+	return a.list[i]
+}
+
+// At returns the index of the topmost annotation which covers the given
+// cell coordinates. Returns -1 if there is none.
+func (a *Annotations) At(x, y int32) int {
+	for i := len(a.list) - 1; i >= 0; i-- {
+		an := &a.list[i]
+		if an.Contains(x, y, a.width(an)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Editing returns true if an annotation is currently being edited.
+func (a *Annotations) Editing() bool {
+	return a.editing > -1
+}
+
+// Edit begins editing the annotation at the given cell coordinates.
+// If there is none, a new annotation is created there.
+func (a *Annotations) Edit(x, y int32) {
+	a.EndEdit()
+
+	a.editing = a.At(x, y)
+	if a.editing == -1 {
+		a.list = append(a.list, circuit.Annotation{
+			X:     x,
+			Y:     y,
+			Size:  AnnotationSizeDefault,
+			Color: annotationPalette[0],
+		})
+		a.editing = len(a.list) - 1
+	}
+
+	a.modified()
+}
+
+// EndEdit finishes editing the current annotation.
+// The annotation is removed if it has no text.
+func (a *Annotations) EndEdit() {
+	if a.editing == -1 {
+		return
+	}
+
+	if len(a.list[a.editing].Text) == 0 {
+		copy(a.list[a.editing:], a.list[a.editing+1:])
+		a.list = a.list[:len(a.list)-1]
+	}
+
+	a.editing = -1
+	a.modified()
+}
+
+// Char appends the given character to the annotation being edited.
+func (a *Annotations) Char(r rune) {
+	if a.editing == -1 {
+		return
+	}
+
+	a.list[a.editing].Text += string(r)
+	a.modified()
+}
+
+// Backspace removes the last character from the annotation being edited.
+func (a *Annotations) Backspace() {
+	if a.editing == -1 {
+		return
+	}
+
+	txt := []rune(a.list[a.editing].Text)
+	if len(txt) > 0 {
+		a.list[a.editing].Text = string(txt[:len(txt)-1])
+		a.modified()
+	}
+}
+
+// CycleColor sets the colour of the annotation being edited to the
+// next colour in the palette.
+func (a *Annotations) CycleColor() {
+	if a.editing == -1 {
+		return
+	}
+
+	an := &a.list[a.editing]
+	next := 0
+
+	for i, c := range annotationPalette {
+		if c == an.Color {
+			next = (i + 1) % len(annotationPalette)
+			break
+		}
+	}
+
+	an.Color = annotationPalette[next]
+	a.modified()
+}
+
+// Draw renders all annotations, using the camera of the given canvas.
+func (a *Annotations) Draw(mp *util.Mat4, c *Canvas) {
+	if len(a.list) == 0 {
+		return
+	}
+
+	ox, oy := c.Origin()
+	z := float32(c.Zoom())
+
+	mvp := mp.Copy()
+	mvp.Mul(util.Mat4Translate(float32(ox), float32(oy), 0))
+	mvp.Mul(util.Mat4Scale(z, z, 0))
+
+	s := resources.GetShader("Text")
+	s.Use()
+	s.SetMat16("mvp", (*mvp)[:])
+
+	m := resources.GetMesh("Annotations")
+
+	// Rebuild the mesh if needed.
+	if a.changed {
+		a.changed = false
+		m.Commitfv(a.layout(), gl.STREAM_DRAW)
+	}
+
+	a.font.Bind()
+	m.Draw()
+	a.font.Unbind()
+}
+
+// layout returns the vertices for all annotations, in cell coordinates.
+func (a *Annotations) layout() []float32 {
+	a.vertices = a.vertices[:0]
+
+	for i := range a.list {
+		an := &a.list[i]
+		txt := an.Text
+
+		// Show a cursor for the annotation being edited.
+		if i == a.editing {
+			txt += "_"
+		}
+
+		a.vertices = a.font.Layout(a.vertices, float32(an.X), float32(an.Y),
+			a.scale(an), an.Color, txt)
+	}
+
+	return a.vertices
+}
+
+// scale returns the factor by which glyphs of the given annotation
+// are scaled to convert them from pixels to cells.
+func (a *Annotations) scale(an *circuit.Annotation) float32 {
+	return an.Size / float32(a.font.LineHeight())
+}
+
+// width returns the width of the given annotation, in cells.
+func (a *Annotations) width(an *circuit.Annotation) float32 {
+	return a.font.Measure(an.Text) * a.scale(an)
+}
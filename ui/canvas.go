@@ -1,29 +1,54 @@
 package ui
 
 import (
+	"image"
 	"math"
+	"time"
 
+	"wireworld/render"
 	"wireworld/resources"
 	"wireworld/sim"
 	"wireworld/util"
-
-	"github.com/go-gl/gl/v3.3-core/gl"
 )
 
+// Zoom levels define the size of a single cell, in pixels.
+// Values below 1 display multiple cells per pixel.
 const (
-	ZoomMin     = 1
-	ZoomMax     = 30
-	ZoomDefault = 15
+	ZoomMin     = 1.0 / 32
+	ZoomMax     = 30.0
+	ZoomDefault = 15.0
+
+	// ZoomTexture defines the zoom level at or below which cells are
+	// rendered through a state texture, instead of as individual points.
+	ZoomTexture = 3.0
+
+	// zoomStep defines the factor by which a single scroll wheel
+	// step changes the zoom level.
+	zoomStep = 1.2
+
+	// frameMargin defines the fraction of the viewport which is kept
+	// free around an area framed with FrameCells.
+	frameMargin = 0.05
+
+	// zoomSpeed defines how quickly an animated zoom approaches its
+	// target. It is the fraction of the remaining distance covered
+	// in one second, in log space.
+	zoomSpeed = 12.0
 )
 
 // Canvas facilitates panning and zooming and tracks mouse input.
 type Canvas struct {
 	mousePosition [2]int
 	mouseDelta    [2]int
-	origin        [2]int
-	viewport      [2]int
-	zoom          int
+	camera        render.Camera
+	zoomAnchor    [2]float64 // Screen position which stays put while zooming.
+	zoomTarget    float64    // Zoom level being animated towards.
+	zoomTime      time.Time
 	panning       bool
+	chunks        *cellChunks
+	texture       cellTexture
+	chunksStale   bool // Chunks need to be updated from the cell data?
+	textureStale  bool // Texture needs to be updated from the cell data?
 }
 
 // NewCanvas creates a new canvas
@@ -31,39 +56,141 @@ func NewCanvas() *Canvas {
 	return &Canvas{
 		mousePosition: [2]int{0, 0},
 		mouseDelta:    [2]int{0, 0},
-		origin:        [2]int{0, 0},
-		viewport:      [2]int{1, 1},
-		zoom:          ZoomDefault,
-		panning:       false,
+		camera: render.Camera{
+			Zoom:     ZoomDefault,
+			Viewport: [2]int{1, 1},
+		},
+		zoomTarget:   ZoomDefault,
+		panning:      false,
+		chunks:       newCellChunks(),
+		chunksStale:  true,
+		textureStale: true,
+	}
+}
+
+// Release clears canvas resources.
+func (c *Canvas) Release() {
+	c.chunks.Release()
+}
+
+// Update advances any zoom animation in progress.
+// Returns true if the camera has changed.
+func (c *Canvas) Update() bool {
+	now := time.Now()
+	dt := now.Sub(c.zoomTime).Seconds()
+	c.zoomTime = now
+
+	if c.camera.Zoom == c.zoomTarget {
+		return false
+	}
+
+	// Interpolate in log space, so the zoom speed feels the
+	// same at every zoom level.
+	lz := math.Log(c.camera.Zoom)
+	lt := math.Log(c.zoomTarget)
+	lz += (lt - lz) * math.Min(1, dt*zoomSpeed)
+
+	z := math.Exp(lz)
+	if math.Abs(lt-lz) < 1e-3 {
+		z = c.zoomTarget
 	}
+
+	c.setZoomAt(z, c.zoomAnchor[0], c.zoomAnchor[1])
+	return true
 }
 
 func (c *Canvas) Draw(mp *util.Mat4) {
+	if sim.CellsChanged() {
+		c.chunksStale = true
+		c.textureStale = true
+	}
+
+	view := c.VisibleCells()
+
+	if c.camera.Zoom <= ZoomTexture {
+		c.drawTexture(mp, view)
+	} else {
+		c.drawPoints(mp, view)
+	}
+}
+
+// drawPoints draws all visible cells as individual points, which are
+// expanded to quads by the geometry shader.
+func (c *Canvas) drawPoints(mp *util.Mat4, view image.Rectangle) {
 	s := resources.GetShader("CellRenderer")
 	s.Use()
 	s.Set1f("alpha", 1.0)
-	c.setCellMVP(s, mp, c.origin[0], c.origin[1])
+	setPalette(s, CurrentTheme())
+	c.setCellMVP(s, mp, c.camera.Origin[0], c.camera.Origin[1])
 
-	m := resources.GetMesh("CellRenderer")
+	// Upload the chunks whose cell data has changed.
+	if c.chunksStale {
+		c.chunksStale = false
+		c.chunks.Update(sim.Cells())
+	}
 
-	// Upload cell data if it has changed.
-	if sim.CellsChanged() {
-		m.Commitiv(sim.Cells(), gl.STREAM_DRAW)
+	c.chunks.Draw(view)
+}
+
+// drawTexture draws all visible cells as a single state texture.
+func (c *Canvas) drawTexture(mp *util.Mat4, view image.Rectangle) {
+	s := resources.GetShader("CellTexture")
+	s.Use()
+	s.Set1f("alpha", 1.0)
+	setPalette(s, CurrentTheme())
+
+	// When zoomed out below one pixel per cell, each texel covers
+	// multiple cells. This keeps the texture size bounded by the
+	// viewport size.
+	scale := 1
+	if c.camera.Zoom < 1 {
+		scale = int(math.Ceil(1 / c.camera.Zoom))
+	}
+
+	// The texture only covers the visible area, so it needs to be
+	// rebuilt when the viewport moves as well.
+	if c.textureStale || view != c.texture.view || scale != c.texture.scale {
+		c.textureStale = false
+		c.texture.Update(sim.Cells(), view, scale)
 	}
 
-	m.Draw()
+	c.texture.Draw(s, c.cellMVP(mp, c.camera.Origin[0], c.camera.Origin[1]))
 }
 
-// setCellMVP computes the cell MVP matrix for the given shader
-// and position.
-func (c *Canvas) setCellMVP(s *resources.Shader, mp *util.Mat4, x, y int) {
-	w, h := c.viewport[0], c.viewport[1]
-	z := float32(c.zoom)
+// VisibleCells returns the area of cells which is currently visible
+// in the viewport, in cell coordinates.
+func (c *Canvas) VisibleCells() image.Rectangle {
+	return c.camera.VisibleCells()
+}
+
+// CellAt returns the cell coordinates at the given screen position.
+func (c *Canvas) CellAt(x, y float64) (int32, int32) {
+	return c.camera.CellAt(x, y)
+}
+
+// Camera returns a copy of the canvas camera.
+func (c *Canvas) Camera() render.Camera {
+	return c.camera
+}
+
+// cellMVP returns the matrix which maps cell coordinates to the
+// screen, where cell 0/0 is located at the given screen position.
+func (c *Canvas) cellMVP(mp *util.Mat4, x, y float64) *util.Mat4 {
+	z := float32(c.camera.Zoom)
 
 	mvp := mp.Copy()
 	mvp.Mul(util.Mat4Translate(float32(x), float32(y), 0))
 	mvp.Mul(util.Mat4Scale(z, z, 0))
+	return mvp
+}
+
+// setCellMVP computes the cell MVP matrix for the given shader
+// and position.
+func (c *Canvas) setCellMVP(s *resources.Shader, mp *util.Mat4, x, y float64) {
+	w, h := c.camera.Viewport[0], c.camera.Viewport[1]
+	z := float32(c.camera.Zoom)
 
+	mvp := c.cellMVP(mp, x, y)
 	s.SetMat16("mvp", (*mvp)[:])
 	s.Set2f("cellSize", z/float32(w)*2, z/float32(h)*2)
 }
@@ -80,7 +207,7 @@ func (c *Canvas) MousePosition() (int, int) {
 
 // Viewport returns the viewport width and height.
 func (c *Canvas) Viewport() (int, int) {
-	return c.viewport[0], c.viewport[1]
+	return c.camera.Viewport[0], c.camera.Viewport[1]
 }
 
 // SetPanning sets the panning flag. Meaning we are either dragging
@@ -89,42 +216,86 @@ func (c *Canvas) SetPanning(v bool) {
 	c.panning = v
 }
 
+// Origin returns the screen position of cell 0/0.
+func (c *Canvas) Origin() (float64, float64) {
+	return c.camera.Origin[0], c.camera.Origin[1]
+}
+
 // ScrollTo scrolls the viewport to the given, absolute position.
-func (c *Canvas) ScrollTo(x, y int) {
-	c.origin[0] = x
-	c.origin[1] = y
+func (c *Canvas) ScrollTo(x, y float64) {
+	c.camera.Origin[0] = x
+	c.camera.Origin[1] = y
 }
 
 // Zoom returns the current zoom factor.
-func (c *Canvas) Zoom() int {
-	return c.zoom
+func (c *Canvas) Zoom() float64 {
+	return c.camera.Zoom
 }
 
-// SetZoom sets the current zoom factor.
-func (c *Canvas) SetZoom(v int) {
-	c.zoom = util.Clampi(v, ZoomMin, ZoomMax)
+// SetZoom sets the current zoom factor immediately, keeping the
+// center of the viewport in place. This cancels any zoom animation.
+func (c *Canvas) SetZoom(v float64) {
+	v = clampZoom(v)
+	c.zoomTarget = v
+	c.setZoomAt(v, float64(c.camera.Viewport[0])/2, float64(c.camera.Viewport[1])/2)
 }
 
-func (c *Canvas) Resize(w, h int) {
-	c.viewport[0] = w
-	c.viewport[1] = h
+// ZoomTo animates the zoom factor towards v, keeping the given
+// screen position in place.
+func (c *Canvas) ZoomTo(v, x, y float64) {
+	c.zoomTarget = clampZoom(v)
+	c.zoomAnchor[0] = x
+	c.zoomAnchor[1] = y
+	c.zoomTime = time.Now()
 }
 
-func (c *Canvas) Scroll(x, y float64) {
-	ox, oy := float32(c.origin[0]), float32(c.origin[1])
-	zf := float32(c.zoom)
-	zd := float32(y / math.Abs(y))
-	fx := float32(c.mousePosition[0]) - ox
-	fy := float32(c.mousePosition[1]) - oy
+// Center returns the cell coordinates at the center of the viewport,
+// along with the current zoom factor.
+func (c *Canvas) Center() (float64, float64, float64) {
+	x, y := c.camera.Center()
+	return x, y, c.camera.Zoom
+}
+
+// SetCenter centers the viewport on the given cell coordinates and sets
+// the zoom factor. This cancels any zoom animation.
+func (c *Canvas) SetCenter(x, y, zoom float64) {
+	c.camera.Zoom = clampZoom(zoom)
+	c.zoomTarget = c.camera.Zoom
+	c.camera.SetCenter(x, y)
+}
 
-	oldfx := fx / zf
-	oldfy := fy / zf
+// FrameCells centers the viewport on the given area of cells, and zooms
+// in or out so the area fits the viewport. This does nothing if the
+// area is empty.
+func (c *Canvas) FrameCells(r image.Rectangle) {
+	if r.Empty() {
+		return
+	}
+
+	z := c.camera.FitZoom(r, frameMargin)
 
-	c.zoom = util.Clampi(int(zf+zd), ZoomMin, ZoomMax)
-	zf = float32(c.zoom)
+	c.SetCenter(
+		float64(r.Min.X)+float64(r.Dx())/2,
+		float64(r.Min.Y)+float64(r.Dy())/2,
+		z)
+}
 
-	c.origin[0] = int(fx - ((oldfx * zf) - ox))
-	c.origin[1] = int(fy - ((oldfy * zf) - oy))
+// setZoomAt sets the zoom factor to v, while keeping the cell at
+// screen position x/y in the same place.
+func (c *Canvas) setZoomAt(v, x, y float64) {
+	c.camera.ZoomAt(v, x, y)
+}
+
+func (c *Canvas) Resize(w, h int) {
+	c.camera.Viewport[0] = w
+	c.camera.Viewport[1] = h
+}
+
+// Scroll zooms in or out, centered on the mouse cursor.
+func (c *Canvas) Scroll(x, y float64) {
+	c.ZoomTo(c.zoomTarget*math.Pow(zoomStep, y),
+		float64(c.mousePosition[0]),
+		float64(c.mousePosition[1]))
 }
 
 func (c *Canvas) MouseMove(x, y float64) {
@@ -134,7 +305,12 @@ func (c *Canvas) MouseMove(x, y float64) {
 	c.mousePosition[1] = int(y)
 
 	if c.panning {
-		c.origin[0] -= c.mouseDelta[0]
-		c.origin[1] -= c.mouseDelta[1]
+		c.camera.Origin[0] -= float64(c.mouseDelta[0])
+		c.camera.Origin[1] -= float64(c.mouseDelta[1])
 	}
 }
+
+// clampZoom returns v, clamped to the range [ZoomMin, ZoomMax].
+func clampZoom(v float64) float64 {
+	return math.Max(ZoomMin, math.Min(ZoomMax, v))
+}
@@ -2,7 +2,6 @@ package ui
 
 import (
 	"image"
-	"math"
 
 	"wireworld/resources"
 	"wireworld/sim"
@@ -66,6 +65,7 @@ func (c *CellSelector) drawRectangle(mp *util.Mat4) {
 	s := resources.GetShader("CellSelectorRect")
 	s.Use()
 	s.SetMat16("mvp", (*mvp)[:])
+	setColor(s, "color", CurrentTheme().SelectionRect)
 
 	m := resources.GetMesh("CellSelectorRect")
 	m.Draw()
@@ -78,7 +78,8 @@ func (c *CellSelector) drawSelection(mp *util.Mat4) {
 
 	s := resources.GetShader("CellSelectorCells")
 	s.Use()
-	c.setCellMVP(s, mp, c.origin[0], c.origin[1])
+	setColor(s, "color", CurrentTheme().Selection)
+	c.setCellMVP(s, mp, c.camera.Origin[0], c.camera.Origin[1])
 
 	m := resources.GetMesh("CellSelectorCells")
 
@@ -94,10 +95,26 @@ func (c *CellSelector) drawSelection(mp *util.Mat4) {
 // HoverTarget returns the cell coordinates at the current
 // mouse cursor position.
 func (c *CellSelector) HoverTarget() (int32, int32) {
-	z := float64(c.Zoom())
-	x := math.Floor(float64(c.mousePosition[0]-c.origin[0]) / z)
-	y := math.Floor(float64(c.mousePosition[1]-c.origin[1]) / z)
-	return int32(x), int32(y)
+	return c.CellAt(float64(c.mousePosition[0]), float64(c.mousePosition[1]))
+}
+
+// SelectionBounds returns the smallest rectangle which encloses
+// all selected cells. Returns an empty rectangle if there are none.
+func (c *CellSelector) SelectionBounds() image.Rectangle {
+	return c.selection.Bounds()
+}
+
+// Selection returns a sorted copy of the selected cells.
+func (c *CellSelector) Selection() sim.CellList {
+	out := make(sim.CellList, len(c.selection))
+	copy(out, c.selection)
+	out.Sort()
+	return out
+}
+
+// SelectionLen returns the number of selected cells.
+func (c *CellSelector) SelectionLen() int {
+	return c.selection.Len()
 }
 
 // SetAddSelection signals the type that we are adding to an
@@ -125,6 +142,26 @@ func (c *CellSelector) SelectAll() {
 	c.finalizeSelection()
 }
 
+// ClearSignals turns all electron heads and tails in the current
+// selection back into wire. If nothing is selected, this applies
+// to all cells in the simulation.
+func (c *CellSelector) ClearSignals() {
+	sel := c.selection
+	if len(sel) == 0 {
+		sim.ClearSignals()
+		return
+	}
+
+	sim.ClearSignalsIn(sel)
+
+	// Keep the selection in sync with the simulation.
+	for i := 0; i < len(sel)-2; i += 3 {
+		if sel[i+2] == sim.CellHead || sel[i+2] == sim.CellTail {
+			sel[i+2] = sim.CellWire
+		}
+	}
+}
+
 // SelectionDelete deletes the current selection from the simulation.
 func (c *CellSelector) SelectionDelete() {
 	sel := c.selection
@@ -178,6 +215,17 @@ func (c *CellSelector) MouseMove(x, y float64) {
 func (c *CellSelector) MouseButton(button glfw.MouseButton, action glfw.Action, mod glfw.ModifierKey) {
 	c.selecting = (button == glfw.MouseButton2)
 
+	// Ctrl+click selects the entire net under the cursor, instead
+	// of starting a selection rectangle. Adding alt limits the net
+	// to the cells up to the nearest diodes or gates. Only the press
+	// counts, so a rectangle started without ctrl finishes normally.
+	if c.selecting && action == glfw.Press && mod&glfw.ModControl != 0 {
+		x, y := c.HoverTarget()
+		c.SelectNet(x, y, mod&glfw.ModAlt != 0)
+		c.selecting = false
+		return
+	}
+
 	if action == glfw.Press {
 		// Begin a new selection rectangle by storing the current
 		// mouse position. This will form one of the corners of
@@ -206,19 +254,34 @@ func (c *CellSelector) MouseButton(button glfw.MouseButton, action glfw.Action,
 	c.selectionStart = nil
 
 	// Find all cells in the selection rectangle.
+	c.setSelection(c.cellsInArea(sr))
+}
+
+// SelectNet selects all cells which are electrically connected to the
+// cell at x/y. If stopAtJunctions is true, the selection ends at diodes
+// and gates. This honours the add-selection flag.
+func (c *CellSelector) SelectNet(x, y int32, stopAtJunctions bool) {
+	c.selectionStart = nil
+	c.setSelection(sim.Net(x, y, stopAtJunctions))
+}
+
+// setSelection replaces the current selection with set, or adds set to
+// the existing selection if the add-selection flag is set.
+func (c *CellSelector) setSelection(set sim.CellList) {
 	if !c.addSelection {
-		c.selection = c.cellsInArea(sr)
+		c.selection = set
 		c.finalizeSelection()
 		return
 	}
 
 	// Add the selected cells to the existing selection,
-	// while making sure we don't have any duplicates.
-	set := c.cellsInArea(sr)
+	// while making sure we don't have any duplicates. Only the
+	// existing part is searched, as appending unsorts the list.
+	existing := c.selection
 	for i := 0; i < len(set)-2; i += 3 {
 		x, y, v := set[i], set[i+1], set[i+2]
 
-		if !c.selection.Contains(x, y) {
+		if !existing.Contains(x, y) {
 			c.selection = append(c.selection, x, y, v)
 		}
 	}
@@ -230,27 +293,16 @@ func (c *CellSelector) MouseButton(button glfw.MouseButton, action glfw.Action,
 // and it sorts the final selection.
 func (c *CellSelector) finalizeSelection() {
 	c.selection = c.selection.Trim()
+	c.selection.Sort()
 	c.selectionChanged = true
 }
 
 // selectionRect computes and returns the canonical rectangle
 // encompassing all selected grid cells.
 func (c *CellSelector) selectionRect(ra, rb image.Point) image.Rectangle {
-	or := image.Point{
-		int(c.origin[0]),
-		int(c.origin[1]),
-	}
-
-	cs := c.Zoom()
-	rs := ra.Sub(or)
-	re := rb.Sub(or)
-
-	x1 := util.Min(rs.X, re.X) / cs
-	y1 := util.Min(rs.Y, re.Y) / cs
-	x2 := util.Max(rs.X, re.X) / cs
-	y2 := util.Max(rs.Y, re.Y) / cs
-
-	return image.Rect(x1, y1, x2, y2)
+	x1, y1 := c.CellAt(float64(ra.X), float64(ra.Y))
+	x2, y2 := c.CellAt(float64(rb.X), float64(rb.Y))
+	return image.Rect(int(x1), int(y1), int(x2), int(y2))
 }
 
 // cellsInArea finds all cells partially or entirely overlapping
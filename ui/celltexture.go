@@ -0,0 +1,81 @@
+package ui
+
+import (
+	"image"
+
+	"wireworld/render"
+	"wireworld/resources"
+	"wireworld/sim"
+	"wireworld/util"
+)
+
+// cellTexture renders cells by writing their states into a texture,
+// with one texel per cell, and drawing it as a single quad. This is
+// much cheaper than drawing each cell as a point when the zoom level
+// is so low that cells are only a few pixels in size.
+//
+// When zoomed out below one pixel per cell, a single texel covers a
+// square of multiple cells. It then shows the most significant state
+// in that square, so electrons remain visible.
+type cellTexture struct {
+	image *image.RGBA     // Texel data. Dimensions are a power-of-two.
+	view  image.Rectangle // Area of cells covered by the texture.
+	scale int             // Width and height of the cell area per texel.
+}
+
+// Update writes the states of all cells in the given area into the
+// texture and commits it to the GPU. Scale determines the number of
+// cells covered by a single texel, in each direction.
+func (ct *cellTexture) Update(cells sim.CellList, view image.Rectangle, scale int) {
+	w := (view.Dx() + scale - 1) / scale
+	h := (view.Dy() + scale - 1) / scale
+
+	// Only reallocate the image when it has become too small.
+	if ct.image == nil || ct.image.Bounds().Dx() < w || ct.image.Bounds().Dy() < h {
+		ct.image = image.NewRGBA(image.Rect(0, 0, util.Pow2(w), util.Pow2(h)))
+	} else {
+		pix := ct.image.Pix
+		for i := range pix {
+			pix[i] = 0
+		}
+	}
+
+	ct.view = view
+	ct.scale = scale
+	img := ct.image
+
+	for i := 0; i < len(cells)-2; i += 3 {
+		x := int(cells[i]) - view.Min.X
+		y := int(cells[i+1]) - view.Min.Y
+
+		if x < 0 || y < 0 || x >= view.Dx() || y >= view.Dy() {
+			continue
+		}
+
+		n := (y/scale)*img.Stride + (x/scale)*4
+		if render.StatePriority(uint8(cells[i+2])) > render.StatePriority(img.Pix[n]) {
+			img.Pix[n] = uint8(cells[i+2])
+		}
+	}
+
+	m := resources.GetMesh("CellTexture").(*resources.TexturedQuadMesh)
+	m.CommitTexture(img, w, h)
+}
+
+// Draw renders the texture. The mvp matrix is expected to map cell
+// coordinates to the screen.
+func (ct *cellTexture) Draw(s *resources.Shader, mvp *util.Mat4) {
+	v := ct.view
+
+	// The texture may cover slightly more cells than the view, if the
+	// view dimensions are not a multiple of the scale.
+	w := (v.Dx() + ct.scale - 1) / ct.scale * ct.scale
+	h := (v.Dy() + ct.scale - 1) / ct.scale * ct.scale
+
+	m := mvp.Copy()
+	m.Mul(util.Mat4Translate(float32(v.Min.X), float32(v.Min.Y), 0))
+	m.Mul(util.Mat4Scale(float32(w), float32(h), 0))
+
+	s.SetMat16("mvp", (*m)[:])
+	resources.GetMesh("CellTexture").Draw()
+}
@@ -0,0 +1,127 @@
+package ui
+
+import (
+	"image"
+
+	"wireworld/resources"
+	"wireworld/sim"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// chunkShift defines the size of a cell chunk as a power of two.
+// Each chunk covers an area of 1<<chunkShift by 1<<chunkShift cells.
+const chunkShift = 6
+
+// chunkKey identifies a chunk by its chunk coordinates.
+type chunkKey struct {
+	x, y int32
+}
+
+// Bounds returns the area covered by the chunk, in cell coordinates.
+func (k chunkKey) Bounds() image.Rectangle {
+	x, y := int(k.x)<<chunkShift, int(k.y)<<chunkShift
+	return image.Rect(x, y, x+1<<chunkShift, y+1<<chunkShift)
+}
+
+// cellChunk holds the GPU buffer for the cells in a single chunk.
+type cellChunk struct {
+	mesh  resources.Mesh
+	cells sim.CellList // Cells as last committed to the GPU.
+	next  sim.CellList // Cells being collected by the current update.
+}
+
+// cellChunks divides the simulation cells into spatial chunks, each
+// with their own GPU buffer. Only chunks whose contents have changed
+// are re-uploaded, and only chunks which overlap the viewport are drawn.
+type cellChunks struct {
+	chunks map[chunkKey]*cellChunk
+}
+
+// newCellChunks creates a new, empty chunk set.
+func newCellChunks() *cellChunks {
+	return &cellChunks{
+		chunks: make(map[chunkKey]*cellChunk),
+	}
+}
+
+// Release clears all chunk resources.
+func (cc *cellChunks) Release() {
+	for k, ch := range cc.chunks {
+		ch.mesh.Release()
+		delete(cc.chunks, k)
+	}
+}
+
+// Update distributes the given cells over their chunks and commits any
+// chunks which differ from their previous contents to the GPU. Empty
+// cells are not drawn, so they are left out.
+func (cc *cellChunks) Update(cells sim.CellList) {
+	for _, ch := range cc.chunks {
+		ch.next = ch.next[:0]
+	}
+
+	var last *cellChunk
+	var lastKey chunkKey
+
+	for i := 0; i < len(cells)-2; i += 3 {
+		if cells[i+2] == sim.CellEmpty {
+			continue
+		}
+
+		// Cells are sorted, so consecutive cells are likely to
+		// end up in the same chunk. Save ourselves a map lookup.
+		key := chunkKey{cells[i] >> chunkShift, cells[i+1] >> chunkShift}
+		if last == nil || key != lastKey {
+			last = cc.chunks[key]
+			lastKey = key
+
+			if last == nil {
+				last = &cellChunk{mesh: resources.NewCellMesh()}
+				cc.chunks[key] = last
+			}
+		}
+
+		last.next = append(last.next, cells[i], cells[i+1], cells[i+2])
+	}
+
+	for k, ch := range cc.chunks {
+		if len(ch.next) == 0 {
+			ch.mesh.Release()
+			delete(cc.chunks, k)
+			continue
+		}
+
+		if equalCells(ch.cells, ch.next) {
+			continue
+		}
+
+		ch.cells, ch.next = ch.next, ch.cells
+		ch.mesh.Commitiv(ch.cells, gl.DYNAMIC_DRAW)
+	}
+}
+
+// Draw draws all chunks which overlap the given area.
+// The area is defined in cell coordinates.
+func (cc *cellChunks) Draw(view image.Rectangle) {
+	for k, ch := range cc.chunks {
+		if k.Bounds().Overlaps(view) {
+			ch.mesh.Draw()
+		}
+	}
+}
+
+// equalCells returns true if a and b have the same contents.
+func equalCells(a, b sim.CellList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
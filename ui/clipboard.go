@@ -59,12 +59,13 @@ func (c *Clipboard) Draw(mp *util.Mat4) {
 	s := resources.GetShader("CellRenderer")
 	s.Use()
 	s.Set1f("alpha", 0.5)
+	setPalette(s, CurrentTheme())
 
 	if c.uniformChanged {
 		z := c.Zoom() / 2
 		c.setCellMVP(s, mp,
-			c.mousePosition[0]-z,
-			c.mousePosition[1]-z)
+			float64(c.mousePosition[0])-z,
+			float64(c.mousePosition[1])-z)
 		c.uniformChanged = false
 	}
 
@@ -94,8 +95,14 @@ func (c *Clipboard) ClipboardCut() {
 
 // ClipboardCopy copies the current cell selection to the clipboard.
 func (c *Clipboard) ClipboardCopy() {
-	c.clipboard = make(sim.CellList, len(c.selection))
-	copy(c.clipboard, c.selection)
+	c.SetClipboard(c.selection)
+}
+
+// SetClipboard replaces the clipboard contents with a copy of the
+// given cells, so they can be pasted.
+func (c *Clipboard) SetClipboard(set sim.CellList) {
+	c.clipboard = make(sim.CellList, len(set))
+	copy(c.clipboard, set)
 
 	// Treat the selection as a rectangle. Find the smallest
 	// X and Y coordinate values.
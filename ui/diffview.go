@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"wireworld/diff"
+	"wireworld/resources"
+	"wireworld/util"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// DiffView highlights the differences between the simulation and
+// another circuit: added cells in green, removed cells in red and
+// changed cells in yellow.
+type DiffView struct {
+	changes []diff.Change
+	visible bool
+	stale   bool // Mesh needs to be rebuilt?
+}
+
+// NewDiffView creates a new, hidden diff view.
+func NewDiffView() *DiffView {
+	return &DiffView{}
+}
+
+// Visible returns true if the differences are visible.
+func (d *DiffView) Visible() bool {
+	return d.visible
+}
+
+// SetVisible shows or hides the differences.
+func (d *DiffView) SetVisible(v bool) {
+	d.visible = v
+	d.stale = true
+}
+
+// Changes returns the differences currently displayed.
+func (d *DiffView) Changes() []diff.Change {
+	return d.changes
+}
+
+// SetChanges replaces the differences to display. The mesh is only
+// rebuilt if they actually differ from the current set.
+func (d *DiffView) SetChanges(changes []diff.Change) {
+	if len(changes) == len(d.changes) {
+		same := true
+		for i := range changes {
+			if changes[i] != d.changes[i] {
+				same = false
+				break
+			}
+		}
+
+		if same {
+			return
+		}
+	}
+
+	d.changes = changes
+	d.stale = true
+}
+
+// Draw renders the differences, using the camera of the given canvas.
+func (d *DiffView) Draw(mp *util.Mat4, c *Canvas) {
+	if !d.visible {
+		return
+	}
+
+	m := resources.GetMesh("Diff")
+
+	if d.stale {
+		d.stale = false
+		m.Commitiv(diff.Cells(d.changes), gl.STREAM_DRAW)
+	}
+
+	if len(d.changes) == 0 {
+		return
+	}
+
+	s := resources.GetShader("CellRenderer")
+	s.Use()
+	s.Set1f("alpha", 1)
+	setPalette(s, &Theme{Cells: diff.Colors})
+	c.setCellMVP(s, mp, c.camera.Origin[0], c.camera.Origin[1])
+
+	m.Draw()
+}
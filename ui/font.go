@@ -0,0 +1,187 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"wireworld/resources"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// fontAtlasSize defines the dimensions of a font's glyph atlas.
+const fontAtlasSize = 1024
+
+// glyph defines a single glyph, cached in a font atlas.
+type glyph struct {
+	region  image.Rectangle // Location of the glyph in the atlas.
+	bounds  image.Rectangle // Glyph bounds, relative to the dot.
+	advance float32         // Horizontal advance in pixels.
+	ok      bool            // Does the glyph have pixels in the atlas?
+}
+
+// Font renders text as textured quads. Glyphs are rasterised with
+// freetype the first time they are used, and are then cached in a
+// texture atlas.
+type Font struct {
+	face       font.Face
+	atlas      *resources.TextureAtlas
+	glyphs     map[rune]*glyph
+	ascent     int
+	lineHeight int
+}
+
+// NewFont creates a new font for the given typeface and size in pixels.
+// This requires a valid OpenGL context.
+func NewFont(ttf *truetype.Font, size float64) *Font {
+	face := truetype.NewFace(ttf, &truetype.Options{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+
+	m := face.Metrics()
+
+	return &Font{
+		face:       face,
+		atlas:      resources.NewTextureAtlas(fontAtlasSize, fontAtlasSize),
+		glyphs:     make(map[rune]*glyph),
+		ascent:     m.Ascent.Ceil(),
+		lineHeight: (m.Ascent + m.Descent).Ceil(),
+	}
+}
+
+// Release clears font resources.
+func (f *Font) Release() {
+	f.atlas.Release()
+	f.glyphs = nil
+}
+
+// LineHeight returns the height of a single line of text, in pixels.
+func (f *Font) LineHeight() int {
+	return f.lineHeight
+}
+
+// Bind binds the glyph atlas, so text can be rendered.
+func (f *Font) Bind() {
+	f.atlas.Bind()
+}
+
+// Unbind unbinds the glyph atlas.
+func (f *Font) Unbind() {
+	f.atlas.Unbind()
+}
+
+// Measure returns the width of the given text, in pixels.
+// For multiple lines, this is the width of the widest one.
+func (f *Font) Measure(text string) float32 {
+	var w, lw float32
+	for _, r := range text {
+		if r == '\n' {
+			lw = 0
+			continue
+		}
+
+		lw += f.glyph(r).advance
+		if lw > w {
+			w = lw
+		}
+	}
+	return w
+}
+
+// Layout appends the vertices for the given text to dst and returns the
+// resulting set. The top-left corner of the text is placed at x/y and all
+// glyphs are scaled by the given factor. Newlines start a new line. The
+// vertices are laid out as expected by resources.TextMesh.
+func (f *Font) Layout(dst []float32, x, y, scale float32, clr color.NRGBA, text string) []float32 {
+	tw, th := f.atlas.Size()
+	r, g, b, a := colorToFloats(clr)
+
+	pen := x
+	base := y + float32(f.ascent)*scale
+
+	for _, c := range text {
+		if c == '\n' {
+			pen = x
+			base += float32(f.lineHeight) * scale
+			continue
+		}
+
+		gi := f.glyph(c)
+
+		if gi.ok {
+			x1 := pen + float32(gi.bounds.Min.X)*scale
+			y1 := base + float32(gi.bounds.Min.Y)*scale
+			x2 := pen + float32(gi.bounds.Max.X)*scale
+			y2 := base + float32(gi.bounds.Max.Y)*scale
+
+			u1 := float32(gi.region.Min.X) / float32(tw)
+			v1 := float32(gi.region.Min.Y) / float32(th)
+			u2 := float32(gi.region.Max.X) / float32(tw)
+			v2 := float32(gi.region.Max.Y) / float32(th)
+
+			dst = append(dst,
+				x1, y1, u1, v1, r, g, b, a,
+				x2, y1, u2, v1, r, g, b, a,
+				x1, y2, u1, v2, r, g, b, a,
+				x2, y1, u2, v1, r, g, b, a,
+				x2, y2, u2, v2, r, g, b, a,
+				x1, y2, u1, v2, r, g, b, a,
+			)
+		}
+
+		pen += gi.advance * scale
+	}
+
+	return dst
+}
+
+// glyph returns the cached glyph for r. It is rasterised and added to
+// the atlas if it does not yet exist. If the atlas is full, the glyph
+// is not drawn, but still advances the pen.
+func (f *Font) glyph(r rune) *glyph {
+	if g, ok := f.glyphs[r]; ok {
+		return g
+	}
+
+	g := new(glyph)
+	f.glyphs[r] = g
+
+	dr, mask, mp, advance, ok := f.face.Glyph(fixed.P(0, 0), r)
+	if !ok {
+		return g
+	}
+
+	g.advance = float32(advance) / 64
+	g.bounds = dr
+
+	if dr.Empty() {
+		return g
+	}
+
+	// Allocate one pixel of padding around the glyph, to avoid bleeding
+	// of neighbouring glyphs when sampling the texture.
+	region, ok := f.atlas.Allocate(dr.Dx()+2, dr.Dy()+2)
+	if !ok {
+		return g
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, dr.Dx(), dr.Dy()))
+	draw.DrawMask(img, img.Bounds(), image.White, image.ZP, mask, mp, draw.Src)
+
+	g.region = image.Rect(0, 0, dr.Dx(), dr.Dy()).Add(region.Min.Add(image.Pt(1, 1)))
+	g.ok = true
+	f.atlas.Set(g.region, img)
+	return g
+}
+
+// colorToFloats returns the RGBA components of c in the range [0, 1].
+// The colour is treated as non-premultiplied.
+func colorToFloats(c color.NRGBA) (float32, float32, float32, float32) {
+	return float32(c.R) / 255, float32(c.G) / 255,
+		float32(c.B) / 255, float32(c.A) / 255
+}
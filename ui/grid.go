@@ -1,16 +1,23 @@
 package ui
 
 import (
+	"math"
+
+	"wireworld/render"
 	"wireworld/resources"
 	"wireworld/util"
 
 	"github.com/go-gl/gl/v3.3-core/gl"
 )
 
+// GridZoomMin defines the lowest zoom level at which the grid is drawn.
+const GridZoomMin = render.GridZoomMin
+
 // Grid extends a canvas with grid rendering and snapping functionality.
 type Grid struct {
 	*Canvas
 
+	gridZoom       float64 // Zoom level the grid mesh was created for.
 	uniformInvalid bool
 	gridInvalid    bool
 	gridVisible    bool
@@ -43,6 +50,16 @@ func (g *Grid) SetPanning(v bool) {
 	g.uniformInvalid = true
 }
 
+// GridVisible returns true if the grid is visible.
+func (g *Grid) GridVisible() bool {
+	return g.gridVisible
+}
+
+// SetGridVisible sets visibility of the grid background.
+func (g *Grid) SetGridVisible(v bool) {
+	g.gridVisible = v
+}
+
 // ToggleGridVisible toggles visibility of the grid background.
 // Returns the new state.
 func (g *Grid) ToggleGridVisible() bool {
@@ -53,20 +70,28 @@ func (g *Grid) ToggleGridVisible() bool {
 func (g *Grid) Draw(mp *util.Mat4) {
 	g.Canvas.Draw(mp)
 
-	if !g.gridVisible {
+	if !g.gridVisible || g.Zoom() < GridZoomMin {
 		return
 	}
 
 	s := resources.GetShader("Grid")
 	s.Use()
+	setColor(s, "color", CurrentTheme().Grid)
+
+	// The zoom level may have been changed without going through
+	// our Scroll method.
+	if g.gridZoom != g.Zoom() {
+		g.uniformInvalid = true
+		g.gridInvalid = true
+	}
 
 	// Upload shader uniforms if needed.
 	if g.uniformInvalid {
 		g.uniformInvalid = false
 
 		cs := g.Zoom()
-		px := float32(g.origin[0] % cs)
-		py := float32(g.origin[1] % cs)
+		px := float32(math.Mod(g.camera.Origin[0], cs))
+		py := float32(math.Mod(g.camera.Origin[1], cs))
 
 		mvp := mp.Copy()
 		mvp.Mul(util.Mat4Translate(px, py, 0))
@@ -91,14 +116,15 @@ func (g *Grid) Draw(mp *util.Mat4) {
 // of the viewport. Each separated by the zoomed cell size.
 func (g *Grid) createGrid(m resources.Mesh) {
 	cs := g.Zoom()
-	w, h := g.Viewport()
-	w += cs * 2
-	h += cs * 2
+	vw, vh := g.Viewport()
+	w := float64(vw) + cs*2
+	h := float64(vh) + cs*2
 
-	lines := make([]float32, 0, int((w/cs)+(h/cs))*4)
+	g.gridZoom = cs
+	lines := make([]float32, 0, int((w/cs)+(h/cs)+2)*4)
 
 	// Horizontal lines.
-	for y := 0; y < h; y += cs {
+	for y := 0.0; y < h; y += cs {
 		lines = append(lines,
 			float32(-cs),
 			float32(y),
@@ -107,7 +133,7 @@ func (g *Grid) createGrid(m resources.Mesh) {
 	}
 
 	// Vertical lines.
-	for x := 0; x < w; x += cs {
+	for x := 0.0; x < w; x += cs {
 		lines = append(lines,
 			float32(x),
 			float32(-cs),
@@ -0,0 +1,100 @@
+package ui
+
+import (
+	"wireworld/resources"
+	"wireworld/sim"
+	"wireworld/util"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+const (
+	// HeatmapWindowDefault defines the number of generations over which
+	// electron head activity is tracked while the heatmap is visible.
+	HeatmapWindowDefault = 256
+
+	// heatmapAlpha defines the opacity of the heatmap overlay.
+	heatmapAlpha = 0.6
+)
+
+// Heatmap draws electron head activity as a colour gradient over the
+// cells, ranging from blue for rarely active cells to red for the most
+// active ones. Wires which are never active are not covered at all.
+//
+// The activity counts are maintained by the simulation. They are only
+// tracked while the heatmap is visible.
+type Heatmap struct {
+	generation uint64 // Generation of the counts in the mesh.
+	max        int    // Highest count in the mesh.
+	visible    bool
+	stale      bool // Mesh needs to be rebuilt?
+}
+
+// NewHeatmap creates a new, hidden heatmap.
+func NewHeatmap() *Heatmap {
+	return &Heatmap{}
+}
+
+// Visible returns true if the heatmap is visible.
+func (h *Heatmap) Visible() bool {
+	return h.visible
+}
+
+// Max returns the highest head count currently displayed.
+func (h *Heatmap) Max() int {
+	return h.max
+}
+
+// ToggleVisible toggles visibility of the heatmap, and enables or
+// disables activity tracking in the simulation along with it.
+// Returns the new state.
+func (h *Heatmap) ToggleVisible() bool {
+	h.visible = !h.visible
+	h.stale = true
+	h.max = 0
+
+	if h.visible {
+		sim.SetHeatmapWindow(HeatmapWindowDefault)
+	} else {
+		sim.SetHeatmapWindow(0)
+	}
+
+	return h.visible
+}
+
+// Draw renders the heatmap, using the camera of the given canvas.
+func (h *Heatmap) Draw(mp *util.Mat4, c *Canvas) {
+	if !h.visible {
+		return
+	}
+
+	m := resources.GetMesh("Heatmap")
+
+	// The counts only change when the simulation advances.
+	if h.stale || h.generation != sim.Generation() {
+		h.stale = false
+		h.generation = sim.Generation()
+
+		list := sim.Heatmap()
+		h.max = 0
+		for i := 2; i < len(list); i += 3 {
+			if int(list[i]) > h.max {
+				h.max = int(list[i])
+			}
+		}
+
+		m.Commitiv(list, gl.STREAM_DRAW)
+	}
+
+	if h.max == 0 {
+		return
+	}
+
+	s := resources.GetShader("Heatmap")
+	s.Use()
+	s.Set1f("alpha", heatmapAlpha)
+	s.Set1f("maxHeat", float32(h.max))
+	c.setCellMVP(s, mp, c.camera.Origin[0], c.camera.Origin[1])
+
+	m.Draw()
+}
@@ -0,0 +1,243 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+)
+
+// keyModMask defines the modifier keys which are significant when
+// matching a key chord.
+const keyModMask = glfw.ModControl | glfw.ModShift | glfw.ModAlt | glfw.ModSuper
+
+// keyNames maps key names, as used in keymap files, to key codes.
+var keyNames = map[string]glfw.Key{
+	"space":       glfw.KeySpace,
+	"apostrophe":  glfw.KeyApostrophe,
+	"comma":       glfw.KeyComma,
+	"minus":       glfw.KeyMinus,
+	"period":      glfw.KeyPeriod,
+	"slash":       glfw.KeySlash,
+	"semicolon":   glfw.KeySemicolon,
+	"equal":       glfw.KeyEqual,
+	"lbracket":    glfw.KeyLeftBracket,
+	"backslash":   glfw.KeyBackslash,
+	"rbracket":    glfw.KeyRightBracket,
+	"grave":       glfw.KeyGraveAccent,
+	"esc":         glfw.KeyEscape,
+	"enter":       glfw.KeyEnter,
+	"tab":         glfw.KeyTab,
+	"backspace":   glfw.KeyBackspace,
+	"insert":      glfw.KeyInsert,
+	"del":         glfw.KeyDelete,
+	"right":       glfw.KeyRight,
+	"left":        glfw.KeyLeft,
+	"down":        glfw.KeyDown,
+	"up":          glfw.KeyUp,
+	"pageup":      glfw.KeyPageUp,
+	"pagedown":    glfw.KeyPageDown,
+	"home":        glfw.KeyHome,
+	"end":         glfw.KeyEnd,
+	"pause":       glfw.KeyPause,
+	"kp-decimal":  glfw.KeyKPDecimal,
+	"kp-divide":   glfw.KeyKPDivide,
+	"kp-multiply": glfw.KeyKPMultiply,
+	"kp-subtract": glfw.KeyKPSubtract,
+	"kp-add":      glfw.KeyKPAdd,
+	"kp-enter":    glfw.KeyKPEnter,
+	"kp-equal":    glfw.KeyKPEqual,
+}
+
+// modNames defines the names of the modifier keys, in the order in
+// which they are written in a key chord.
+var modNames = []struct {
+	name string
+	mod  glfw.ModifierKey
+}{
+	{"ctrl", glfw.ModControl},
+	{"alt", glfw.ModAlt},
+	{"shift", glfw.ModShift},
+	{"super", glfw.ModSuper},
+}
+
+func init() {
+	for k := glfw.KeyA; k <= glfw.KeyZ; k++ {
+		keyNames[string(rune('a'+k-glfw.KeyA))] = k
+	}
+
+	for k := glfw.Key0; k <= glfw.Key9; k++ {
+		keyNames[string(rune('0'+k-glfw.Key0))] = k
+		keyNames[fmt.Sprintf("kp-%d", k-glfw.Key0)] = glfw.KeyKP0 + (k - glfw.Key0)
+	}
+
+	for k := glfw.KeyF1; k <= glfw.KeyF12; k++ {
+		keyNames[fmt.Sprintf("f%d", k-glfw.KeyF1+1)] = k
+	}
+}
+
+// KeyChord defines a key, along with the modifier keys which must be
+// held down with it.
+type KeyChord struct {
+	Key  glfw.Key
+	Mods glfw.ModifierKey
+}
+
+// ParseKeyChord parses a key chord like "ctrl-shift-z". Modifiers come
+// first, followed by the key name. Names are case insensitive.
+func ParseKeyChord(v string) (KeyChord, error) {
+	var kc KeyChord
+
+	fields := strings.Split(strings.ToLower(strings.TrimSpace(v)), "-")
+
+	// Keypad keys contain a dash in their name.
+	if n := len(fields); n > 1 && fields[n-2] == "kp" {
+		fields = append(fields[:n-2], "kp-"+fields[n-1])
+	}
+
+	for _, f := range fields[:len(fields)-1] {
+		found := false
+		for _, m := range modNames {
+			if m.name == f {
+				kc.Mods |= m.mod
+				found = true
+			}
+		}
+
+		if !found {
+			return kc, fmt.Errorf("invalid key %q: unknown modifier %q", v, f)
+		}
+	}
+
+	key, ok := keyNames[fields[len(fields)-1]]
+	if !ok {
+		return kc, fmt.Errorf("invalid key %q", v)
+	}
+
+	kc.Key = key
+	return kc, nil
+}
+
+// String returns the chord in the form accepted by ParseKeyChord.
+func (kc KeyChord) String() string {
+	var sb strings.Builder
+
+	for _, m := range modNames {
+		if kc.Mods&m.mod != 0 {
+			sb.WriteString(m.name)
+			sb.WriteString("-")
+		}
+	}
+
+	for name, key := range keyNames {
+		if key == kc.Key {
+			sb.WriteString(name)
+			return sb.String()
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("key%d", kc.Key))
+	return sb.String()
+}
+
+// Keymap binds key chords to named actions. A chord may be bound to
+// multiple actions. It is up to the caller to decide which of those
+// applies in the current context.
+type Keymap struct {
+	bindings map[string][]KeyChord
+}
+
+// NewKeymap creates a keymap from the given action names and their
+// key chords.
+func NewKeymap(bindings map[string][]string) (*Keymap, error) {
+	km := Keymap{
+		bindings: make(map[string][]KeyChord),
+	}
+
+	for action, keys := range bindings {
+		if err := km.Bind(action, keys...); err != nil {
+			return nil, err
+		}
+	}
+
+	return &km, nil
+}
+
+// Bind replaces the key chords for the given action.
+// Binding no keys at all unbinds the action.
+func (km *Keymap) Bind(action string, keys ...string) error {
+	set := make([]KeyChord, 0, len(keys))
+
+	for _, v := range keys {
+		kc, err := ParseKeyChord(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", action, err)
+		}
+		set = append(set, kc)
+	}
+
+	km.bindings[action] = set
+	return nil
+}
+
+// Keys returns the key chords bound to the given action.
+func (km *Keymap) Keys(action string) []KeyChord {
+	return km.bindings[action]
+}
+
+// Actions returns the sorted names of all actions bound to the given
+// key and modifiers.
+func (km *Keymap) Actions(key glfw.Key, mods glfw.ModifierKey) []string {
+	kc := KeyChord{key, mods & keyModMask}
+
+	var out []string
+	for action, set := range km.bindings {
+		for _, v := range set {
+			if v == kc {
+				out = append(out, action)
+				break
+			}
+		}
+	}
+
+	sort.Strings(out)
+	return out
+}
+
+// keymapFile defines the on-disk layout of a keymap file.
+type keymapFile struct {
+	Bindings map[string][]string `json:"bindings"`
+}
+
+// Load reads key bindings from the given JSON file. Each entry maps
+// an action name onto a list of key chords and replaces the existing
+// bindings for that action. Valid reports whether an action name is
+// known. Unknown actions are treated as an error.
+func (km *Keymap) Load(file string, valid func(action string) bool) error {
+	fd, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer fd.Close()
+
+	var kf keymapFile
+	if err := json.NewDecoder(fd).Decode(&kf); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	for action, keys := range kf.Bindings {
+		if !valid(action) {
+			return fmt.Errorf("%s: unknown action %q", file, action)
+		}
+
+		if err := km.Bind(action, keys...); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"time"
+
+	"wireworld/render"
+	"wireworld/resources"
+	"wireworld/sim"
+	"wireworld/util"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+)
+
+// minimapInterval defines how often the minimap image is redrawn.
+// Redrawing requires a pass over all cells, so we do not want to do
+// this every frame.
+const minimapInterval = 250 * time.Millisecond
+
+// Minimap displays a downscaled view of the whole circuit, along with
+// a rectangle showing the area visible in the canvas. Clicking or
+// dragging in the minimap pans the canvas.
+type Minimap struct {
+	x, y, w, h int
+	image      *image.RGBA
+	states     []uint8         // Cell state shown at each pixel.
+	bounds     image.Rectangle // Area of cells shown in the minimap.
+	scale      float64         // Minimap pixels per cell.
+	offset     [2]float64      // Pixel offset of bounds.Min in the minimap.
+	updateTime time.Time
+	dragging   bool
+	visible    bool
+}
+
+// NewMinimap creates a new minimap.
+func NewMinimap() *Minimap {
+	return &Minimap{
+		scale:   1,
+		visible: true,
+	}
+}
+
+// Resize resizes and positions the minimap.
+func (m *Minimap) Resize(x, y, w, h int) {
+	if m.w != w || m.h != h {
+		m.image = image.NewRGBA(image.Rect(0, 0, util.Pow2(w), util.Pow2(h)))
+		m.states = make([]uint8, w*h)
+		m.updateTime = time.Time{}
+	}
+
+	m.x = x
+	m.y = y
+	m.w = w
+	m.h = h
+}
+
+// ToggleVisible toggles visibility of the minimap.
+// Returns the new state.
+func (m *Minimap) ToggleVisible() bool {
+	m.visible = !m.visible
+	m.dragging = false
+	return m.visible
+}
+
+// Contains returns true if the given screen position is inside the minimap.
+func (m *Minimap) Contains(x, y float64) bool {
+	return m.visible && image.Pt(int(x), int(y)).In(image.Rect(m.x, m.y, m.x+m.w, m.y+m.h))
+}
+
+// MouseButton handles a mouse button event for the given canvas.
+// Returns true if the event was handled by the minimap.
+func (m *Minimap) MouseButton(c *Canvas, button glfw.MouseButton, action glfw.Action) bool {
+	if button != glfw.MouseButton1 {
+		return false
+	}
+
+	if action == glfw.Release {
+		handled := m.dragging
+		m.dragging = false
+		return handled
+	}
+
+	mx, my := c.MousePosition()
+	if !m.Contains(float64(mx), float64(my)) {
+		return false
+	}
+
+	m.dragging = true
+	m.panTo(c, mx, my)
+	return true
+}
+
+// MouseMove pans the given canvas if we are dragging in the minimap.
+// Returns true if the event was handled by the minimap.
+func (m *Minimap) MouseMove(c *Canvas) bool {
+	if !m.dragging {
+		return false
+	}
+
+	mx, my := c.MousePosition()
+	m.panTo(c, mx, my)
+	return true
+}
+
+// panTo centers the canvas on the cell shown at the given screen position.
+func (m *Minimap) panTo(c *Canvas, x, y int) {
+	cx := float64(m.bounds.Min.X) + (float64(x-m.x)-m.offset[0])/m.scale
+	cy := float64(m.bounds.Min.Y) + (float64(y-m.y)-m.offset[1])/m.scale
+
+	_, _, zoom := c.Center()
+	c.SetCenter(cx, cy, zoom)
+}
+
+func (m *Minimap) Draw(mp *util.Mat4, c *Canvas) {
+	if !m.visible {
+		return
+	}
+
+	if time.Since(m.updateTime) >= minimapInterval {
+		m.updateTime = time.Now()
+		m.update(sim.Cells(), c.VisibleCells())
+	}
+
+	m.drawBackground(mp)
+	m.drawImage(mp)
+	m.drawViewport(mp, c.VisibleCells())
+}
+
+// update recomputes the area shown in the minimap and redraws the image.
+func (m *Minimap) update(cells sim.CellList, view image.Rectangle) {
+	// Show the whole circuit. Include the visible area, so the
+	// viewport rectangle is always in view.
+	b := cells.Bounds()
+	if b.Empty() {
+		b = view
+	} else {
+		b = b.Union(view)
+	}
+
+	m.bounds = b
+	m.scale = math.Min(float64(m.w)/float64(b.Dx()), float64(m.h)/float64(b.Dy()))
+	m.offset[0] = (float64(m.w) - float64(b.Dx())*m.scale) / 2
+	m.offset[1] = (float64(m.h) - float64(b.Dy())*m.scale) / 2
+
+	// Theme colours are not premultiplied, unlike the image.
+	var palette [4]color.RGBA
+	for i, c := range CurrentTheme().Cells {
+		palette[i] = color.RGBAModel.Convert(c).(color.RGBA)
+	}
+
+	img := m.image
+	for i := range img.Pix {
+		img.Pix[i] = 0
+	}
+
+	for i := range m.states {
+		m.states[i] = sim.CellEmpty
+	}
+
+	for i := 0; i < len(cells)-2; i += 3 {
+		v := uint8(cells[i+2])
+		x := int(m.offset[0] + float64(int(cells[i])-b.Min.X)*m.scale)
+		y := int(m.offset[1] + float64(int(cells[i+1])-b.Min.Y)*m.scale)
+
+		if x < 0 || y < 0 || x >= m.w || y >= m.h {
+			continue
+		}
+
+		// Multiple cells can end up on the same pixel.
+		// Make sure the most significant one is shown.
+		if render.StatePriority(v) <= render.StatePriority(m.states[y*m.w+x]) {
+			continue
+		}
+
+		m.states[y*m.w+x] = v
+		img.SetRGBA(x, y, palette[v&3])
+	}
+
+	mesh := resources.GetMesh("Minimap").(*resources.TexturedQuadMesh)
+	mesh.CommitTexture(img, m.w, m.h)
+}
+
+// drawBackground draws the minimap background.
+func (m *Minimap) drawBackground(mp *util.Mat4) {
+	mvp := mp.Copy()
+	mvp.Mul(util.Mat4Translate(float32(m.x), float32(m.y), 0))
+	mvp.Mul(util.Mat4Scale(float32(m.w), float32(m.h), 0))
+
+	s := resources.GetShader("Panel")
+	s.Use()
+	s.SetMat16("mvp", mvp[:])
+	setColor(s, "color", CurrentTheme().Panel)
+
+	resources.GetMesh("Panel").Draw()
+}
+
+// drawImage draws the downscaled cells.
+func (m *Minimap) drawImage(mp *util.Mat4) {
+	mvp := mp.Copy()
+	mvp.Mul(util.Mat4Translate(float32(m.x), float32(m.y), 0))
+	mvp.Mul(util.Mat4Scale(float32(m.w), float32(m.h), 0))
+
+	s := resources.GetShader("Texture")
+	s.Use()
+	s.SetMat16("mvp", mvp[:])
+
+	resources.GetMesh("Minimap").Draw()
+}
+
+// drawViewport draws the rectangle marking the visible area of the canvas.
+func (m *Minimap) drawViewport(mp *util.Mat4, view image.Rectangle) {
+	x := float64(m.x) + m.offset[0] + float64(view.Min.X-m.bounds.Min.X)*m.scale
+	y := float64(m.y) + m.offset[1] + float64(view.Min.Y-m.bounds.Min.Y)*m.scale
+	w := math.Max(1, float64(view.Dx())*m.scale)
+	h := math.Max(1, float64(view.Dy())*m.scale)
+
+	mvp := mp.Copy()
+	mvp.Mul(util.Mat4Translate(float32(x), float32(y), 0))
+	mvp.Mul(util.Mat4Scale(float32(w), float32(h), 0))
+
+	s := resources.GetShader("CellSelectorRect")
+	s.Use()
+	s.SetMat16("mvp", mvp[:])
+	setColor(s, "color", CurrentTheme().SelectionRect)
+
+	resources.GetMesh("CellSelectorRect").Draw()
+}
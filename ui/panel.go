@@ -2,71 +2,90 @@ package ui
 
 import (
 	"fmt"
-	"image"
 	"image/color"
-	"image/draw"
 
 	"wireworld/resources"
 	"wireworld/util"
 
-	"github.com/golang/freetype"
+	"github.com/go-gl/gl/v3.3-core/gl"
 )
 
 // InfoPanel defines a rectangular panel with debug information.
 type InfoPanel struct {
-	x, y, w, h     int
-	image          *image.RGBA
-	textureChanged bool
+	x, y, w, h  int
+	mesh        resources.Mesh
+	lines       []string // Current panel contents.
+	committed   []string // Contents as last committed to the GPU.
+	textColor   color.NRGBA
+	vertices    []float32
+	textChanged bool
 }
 
 // NewPanel creates a new debug info panel.
 func NewInfoPanel() *InfoPanel {
 	var p InfoPanel
+	p.mesh = resources.NewTextMesh()
 	return &p
 }
 
 func (p *InfoPanel) Release() {
-	p.image = nil
+	if p == nil {
+		return
+	}
+
+	p.mesh.Release()
+	p.lines = nil
+	p.committed = nil
 }
 
 // Resize resizes and positions the button.
 func (p *InfoPanel) Resize(x, y, w, h int) {
-	if p.w != w || p.h != h {
-		p.textureChanged = true
-		p.image = image.NewRGBA(image.Rect(0, 0, util.Pow2(w), util.Pow2(h)))
-		p.w = w
-		p.h = h
+	if p.x != x || p.y != y {
+		p.textChanged = true
 	}
 
 	p.x = x
 	p.y = y
+	p.w = w
+	p.h = h
+}
+
+// TextSize returns the width and height, in pixels, needed to
+// display the current panel contents.
+func (p *InfoPanel) TextSize() (int, int) {
+	f := regularFont()
+
+	var w float32
+	for _, v := range p.lines {
+		if lw := f.Measure(v); lw > w {
+			w = lw
+		}
+	}
+
+	return int(w) + 10, len(p.lines) * fontRegularLineHeight
 }
 
 // Clear clears all panel contents.
 func (p *InfoPanel) Clear() {
-	clr := image.NewUniform(color.RGBA{0xb3, 0xb3, 0xb3, 0xff})
-	draw.Draw(p.image, p.image.Bounds(), clr, image.ZP, draw.Src)
-	p.textureChanged = true
+	p.lines = p.lines[:0]
 }
 
 // Print sets the given line to the specified formatted content.
 func (p *InfoPanel) Print(line int, v string, argv ...interface{}) {
-	v = fmt.Sprintf(v, argv...)
-	if len(v) == 0 {
-		return
+	for len(p.lines) <= line {
+		p.lines = append(p.lines, "")
 	}
 
-	x := p.x + 5
-	y := p.y + (line+1)*fontRegularLineHeight
-
-	fontRegular.SetClip(p.image.Bounds())
-	fontRegular.SetDst(p.image)
-	fontRegular.DrawString(v, freetype.Pt(x, y))
-
-	p.textureChanged = true
+	p.lines[line] = fmt.Sprintf(v, argv...)
 }
 
 func (p *InfoPanel) Draw(mp *util.Mat4) {
+	p.drawBackground(mp)
+	p.drawText(mp)
+}
+
+// drawBackground draws the panel background.
+func (p *InfoPanel) drawBackground(mp *util.Mat4) {
 	mvp := mp.Copy()
 	mvp.Mul(util.Mat4Translate(float32(p.x), float32(p.y), 0))
 	mvp.Mul(util.Mat4Scale(float32(p.w), float32(p.h), 0))
@@ -74,14 +93,64 @@ func (p *InfoPanel) Draw(mp *util.Mat4) {
 	s := resources.GetShader("Panel")
 	s.Use()
 	s.SetMat16("mvp", mvp[:])
+	setColor(s, "color", CurrentTheme().Panel)
 
-	m := resources.GetMesh("Panel").(*resources.TexturedQuadMesh)
+	m := resources.GetMesh("Panel")
+	m.Draw()
+}
 
-	// Upload texture, if applicable.
-	if p.textureChanged {
-		p.textureChanged = false
-		m.CommitTexture(p.image, p.w, p.h)
+// drawText draws the panel contents. The text is only laid out and
+// committed to the GPU if it has changed since the last call.
+func (p *InfoPanel) drawText(mp *util.Mat4) {
+	s := resources.GetShader("Text")
+	s.Use()
+	s.SetMat16("mvp", mp[:])
+
+	f := regularFont()
+	m := p.mesh
+
+	clr := CurrentTheme().PanelText
+
+	if p.textChanged || clr != p.textColor || !equalLines(p.lines, p.committed) {
+		p.textChanged = false
+		p.textColor = clr
+		p.committed = append(p.committed[:0], p.lines...)
+		m.Commitfv(p.layout(f), gl.STREAM_DRAW)
 	}
 
+	f.Bind()
 	m.Draw()
+	f.Unbind()
+}
+
+// layout returns the vertices for the current panel contents.
+func (p *InfoPanel) layout(f *Font) []float32 {
+	p.vertices = p.vertices[:0]
+
+	x := float32(p.x + 5)
+	for i, v := range p.lines {
+		if len(v) == 0 {
+			continue
+		}
+
+		y := float32(p.y + i*fontRegularLineHeight)
+		p.vertices = f.Layout(p.vertices, x, y, 1, p.textColor, v)
+	}
+
+	return p.vertices
+}
+
+// equalLines returns true if a and b have the same contents.
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
 }
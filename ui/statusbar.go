@@ -0,0 +1,118 @@
+package ui
+
+import (
+	"time"
+
+	"wireworld/sim"
+	"wireworld/util"
+)
+
+// stepRateInterval defines how often the measured step rate is updated.
+const stepRateInterval = 500 * time.Millisecond
+
+// StatusBar displays a single line of status information at the bottom
+// of the window, along with an optional tooltip next to the cursor.
+type StatusBar struct {
+	panel          *InfoPanel
+	tooltip        *InfoPanel
+	tooltipVisible bool      // Tooltip has contents?
+	tooltipEnabled bool      // Tooltips are shown at all?
+	rateTime       time.Time // Time of the last step rate sample.
+	rateGeneration uint64    // Generation at the last step rate sample.
+	rate           float64
+}
+
+// NewStatusBar creates a new, empty status bar.
+func NewStatusBar() *StatusBar {
+	return &StatusBar{
+		panel:          NewInfoPanel(),
+		tooltip:        NewInfoPanel(),
+		tooltipEnabled: true,
+		rateTime:       time.Now(),
+		rateGeneration: sim.Generation(),
+	}
+}
+
+// Release clears status bar resources.
+func (sb *StatusBar) Release() {
+	if sb == nil {
+		return
+	}
+
+	sb.panel.Release()
+	sb.tooltip.Release()
+}
+
+// Height returns the height of the status bar, in pixels.
+func (sb *StatusBar) Height() int {
+	return fontRegularLineHeight + 2
+}
+
+// Resize positions the status bar at the bottom of a window with the
+// given dimensions.
+func (sb *StatusBar) Resize(w, h int) {
+	sb.panel.Resize(0, h-sb.Height(), w, sb.Height())
+}
+
+// Print sets the status text to the specified formatted content.
+func (sb *StatusBar) Print(v string, argv ...interface{}) {
+	sb.panel.Print(0, v, argv...)
+}
+
+// ToggleTooltip toggles display of the tooltip. Returns the new state.
+func (sb *StatusBar) ToggleTooltip() bool {
+	sb.tooltipEnabled = !sb.tooltipEnabled
+	return sb.tooltipEnabled
+}
+
+// SetTooltip sets the tooltip contents and places it near the given
+// screen position. The tooltip is hidden if there are no lines.
+func (sb *StatusBar) SetTooltip(x, y int, lines ...string) {
+	sb.tooltipVisible = len(lines) > 0
+	if !sb.tooltipVisible {
+		return
+	}
+
+	sb.tooltip.Clear()
+	for i, v := range lines {
+		sb.tooltip.Print(i, "%s", v)
+	}
+
+	// Keep the tooltip inside the area above the status bar.
+	w, h := sb.tooltip.TextSize()
+	x, y = x+16, y+16
+
+	if x+w > sb.panel.w {
+		x = util.Max(0, sb.panel.w-w)
+	}
+
+	if y+h > sb.panel.y {
+		y = util.Max(0, sb.panel.y-h)
+	}
+
+	sb.tooltip.Resize(x, y, w, h)
+}
+
+// StepRate returns the number of simulation steps performed per second,
+// as measured over the most recent sampling interval.
+func (sb *StatusBar) StepRate() float64 {
+	now := time.Now()
+	dt := now.Sub(sb.rateTime)
+	if dt < stepRateInterval {
+		return sb.rate
+	}
+
+	gen := sim.Generation()
+	sb.rate = float64(gen-sb.rateGeneration) / dt.Seconds()
+	sb.rateGeneration = gen
+	sb.rateTime = now
+	return sb.rate
+}
+
+func (sb *StatusBar) Draw(mp *util.Mat4) {
+	sb.panel.Draw(mp)
+
+	if sb.tooltipEnabled && sb.tooltipVisible {
+		sb.tooltip.Draw(mp)
+	}
+}
@@ -0,0 +1,152 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+
+	"wireworld/render"
+	"wireworld/resources"
+	"wireworld/util"
+)
+
+// Theme defines the colours used to draw the UI. It is shared with
+// the software renderer.
+type Theme = render.Theme
+
+// themes defines all known themes. The first entry is the default.
+var themes = render.DefaultThemes()
+
+// currentTheme defines the theme currently in use.
+var currentTheme = themes[0]
+
+// CurrentTheme returns the theme currently in use.
+func CurrentTheme() *Theme {
+	return currentTheme
+}
+
+// ThemeNames returns the names of all known themes.
+func ThemeNames() []string {
+	out := make([]string, len(themes))
+	for i, t := range themes {
+		out[i] = t.Name
+	}
+	return out
+}
+
+// SetTheme selects the theme with the given name.
+// Returns false if there is no such theme.
+func SetTheme(name string) bool {
+	for _, t := range themes {
+		if t.Name == name {
+			currentTheme = t
+			return true
+		}
+	}
+	return false
+}
+
+// CycleTheme selects the next known theme and returns its name.
+func CycleTheme() string {
+	for i, t := range themes {
+		if t == currentTheme {
+			currentTheme = themes[(i+1)%len(themes)]
+			break
+		}
+	}
+	return currentTheme.Name
+}
+
+// themeFile defines the on-disk layout of a theme file.
+type themeFile struct {
+	Themes []struct {
+		Name          string    `json:"name"`
+		Background    string    `json:"background"`
+		Cells         [4]string `json:"cells"`
+		Grid          string    `json:"grid"`
+		Selection     string    `json:"selection"`
+		SelectionRect string    `json:"selectionRect"`
+		Panel         string    `json:"panel"`
+		PanelText     string    `json:"panelText"`
+	} `json:"themes"`
+}
+
+// LoadThemes loads additional themes from the given JSON file. A theme
+// with the same name as an existing one replaces it. Colours are written
+// as #rrggbb or #rrggbbaa. Any colours left out are copied from the
+// default theme.
+func LoadThemes(file string) error {
+	fd, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer fd.Close()
+
+	var tf themeFile
+	if err := json.NewDecoder(fd).Decode(&tf); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	for _, ft := range tf.Themes {
+		t := *themes[0]
+		t.Name = ft.Name
+
+		// Parse each colour which has been specified.
+		parse := func(dst *color.NRGBA, v string) {
+			if len(v) > 0 && err == nil {
+				*dst, err = util.ParseColor(v)
+			}
+		}
+
+		parse(&t.Background, ft.Background)
+		for i := range ft.Cells {
+			parse(&t.Cells[i], ft.Cells[i])
+		}
+		parse(&t.Grid, ft.Grid)
+		parse(&t.Selection, ft.Selection)
+		parse(&t.SelectionRect, ft.SelectionRect)
+		parse(&t.Panel, ft.Panel)
+		parse(&t.PanelText, ft.PanelText)
+
+		if err != nil {
+			return fmt.Errorf("%s: theme %q: %v", file, ft.Name, err)
+		}
+
+		addTheme(&t)
+	}
+
+	return nil
+}
+
+// addTheme adds t to the known themes, replacing any existing
+// theme with the same name.
+func addTheme(t *Theme) {
+	for i, v := range themes {
+		if v.Name == t.Name {
+			if currentTheme == v {
+				currentTheme = t
+			}
+			themes[i] = t
+			return
+		}
+	}
+
+	themes = append(themes, t)
+}
+
+// setColor sets the given vec4 uniform to colour c.
+func setColor(s *resources.Shader, name string, c color.NRGBA) {
+	r, g, b, a := colorToFloats(c)
+	s.Set4f(name, r, g, b, a)
+}
+
+// setPalette sets the cell palette uniforms for the given shader.
+func setPalette(s *resources.Shader, t *Theme) {
+	var v [16]float32
+	for i, c := range t.Cells {
+		v[i*4+0], v[i*4+1], v[i*4+2], v[i*4+3] = colorToFloats(c)
+	}
+	s.Set4fv("palette", v[:])
+}
@@ -2,10 +2,8 @@
 package ui
 
 import (
-	"image"
-
 	"github.com/golang/freetype"
-	"golang.org/x/image/font"
+	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font/gofont/goregular"
 )
 
@@ -15,7 +13,8 @@ const (
 )
 
 var (
-	fontRegular           *freetype.Context
+	fontRegularTTF        *truetype.Font
+	fontRegularGlyphs     *Font
 	fontRegularLineHeight int
 )
 
@@ -25,12 +24,24 @@ func init() {
 		panic(err)
 	}
 
-	fontRegular = freetype.NewContext()
-	fontRegular.SetDPI(fontRegularDPI)
-	fontRegular.SetFont(ttf)
-	fontRegular.SetFontSize(fontRegularSize)
-	fontRegular.SetHinting(font.HintingFull)
-	fontRegular.SetSrc(image.Black)
+	fontRegularTTF = ttf
+	fontRegularLineHeight = (fontRegularSize + 2) * fontRegularDPI / 72
+}
+
+// regularFont returns the font used for regular UI text. It is shared by
+// all components and created on first use. This requires a valid OpenGL
+// context.
+func regularFont() *Font {
+	if fontRegularGlyphs == nil {
+		fontRegularGlyphs = NewFont(fontRegularTTF, fontRegularSize*fontRegularDPI/72.0)
+	}
+	return fontRegularGlyphs
+}
 
-	fontRegularLineHeight = int(fontRegular.PointToFixed(fontRegularSize+2) >> 6)
+// Release clears resources shared by all UI components.
+func Release() {
+	if fontRegularGlyphs != nil {
+		fontRegularGlyphs.Release()
+		fontRegularGlyphs = nil
+	}
 }
@@ -52,7 +52,6 @@ func CreateWindow(width, height int, fullscreen bool) (*Window, error) {
 
 	gl.Enable(gl.BLEND)
 	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
-	gl.ClearColor(0.9, 0.9, 0.9, 1.0)
 	return &w, nil
 }
 
@@ -66,6 +65,7 @@ func (w *Window) Release() {
 		w.SetCursorPosCallback(nil)
 		w.SetCharCallback(nil)
 		w.SetScrollCallback(nil)
+		w.SetDropCallback(nil)
 		w.SetUserPointer(nil)
 		w.Destroy()
 		w.Window = nil
@@ -1,6 +1,33 @@
 // Package util defines some utility/helper types and functions.
 package util
 
+import (
+	"fmt"
+	"image/color"
+)
+
+// FormatColor returns c as a hex string in the form #rrggbbaa.
+func FormatColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// ParseColor parses a colour in the form #rrggbb or #rrggbbaa.
+func ParseColor(v string) (color.NRGBA, error) {
+	c := color.NRGBA{A: 0xff}
+
+	var err error
+	switch len(v) {
+	case 7:
+		_, err = fmt.Sscanf(v, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 9:
+		_, err = fmt.Sscanf(v, "#%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A)
+	default:
+		err = fmt.Errorf("invalid colour %q", v)
+	}
+
+	return c, err
+}
+
 // Pow2 returns the first power-of-two value >= to n.
 // This can be used to create suitable texture dimensions.
 func Pow2(n int) int {